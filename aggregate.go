@@ -0,0 +1,164 @@
+package bitset
+
+import (
+	"sort"
+)
+
+// UnionAll gets a new set of the members of any of the given sets. The range
+// of the result is found first, so a bitset is allocated only once.
+func UnionAll(sets ...*IntSet) *IntSet {
+	lo, hi := uint(0), uint(0)
+	nonEmpty := make([]*IntSet, 0, len(sets))
+	flat, chunked := true, false // no bitsets or chunks, or some chunks
+	for _, set := range sets {
+		if set.IsEmpty() {
+			continue
+		}
+		if len(nonEmpty) == 0 {
+			lo, hi = set.minValue, set.maxValue
+		}
+		lo, hi = rangeUnion(lo, hi, set.minValue, set.maxValue)
+		flat = flat && set.vs == nil && set.chunks == nil
+		chunked = chunked || set.chunks != nil
+		nonEmpty = append(nonEmpty, set)
+	}
+	switch {
+	case len(nonEmpty) == 0:
+		return NewIntSet()
+	case len(nonEmpty) == 1:
+		return nonEmpty[0].Clone().SetAutoOptimize(false)
+	case flat:
+		var runs []run
+		for _, set := range nonEmpty {
+			runs = append(runs, set.asRuns()...)
+		}
+		return NewIntSet().setRuns(sortRuns(runs))
+	}
+	if chunked || hi-lo >= maxBitSetSpan {
+		return unionAllChunks(nonEmpty)
+	}
+	result := &IntSet{minValue: lo, maxValue: hi, vsStart: lo &^ 0x3F, vs: make([]uint64, (hi>>6)-(lo>>6)+1)}
+	for _, set := range nonEmpty {
+		if set.vs == nil {
+			for _, r := range set.asRuns() {
+				result.setBitRange(r.minValue, r.maxValue)
+			}
+			continue
+		}
+		start := (set.minValue - set.vsStart) >> 6
+		end := (set.maxValue - set.vsStart) >> 6
+		offset := (set.vsStart + start<<6 - result.vsStart) >> 6
+		for i, w := range set.vs[start : end+1] {
+			result.vs[offset+uint(i)] |= w
+		}
+	}
+	result.countMembers()
+	return result
+}
+
+// unionAllChunks unions the sets chunk by chunk, where at least one is
+// chunked or they span too many values for a bitset
+func unionAllChunks(sets []*IntSet) *IntSet {
+	if wideChunks(sets) {
+		var runs []run
+		for _, set := range sets {
+			runs = append(runs, set.asRuns()...)
+		}
+		return NewIntSet().setRuns(sortRuns(runs))
+	}
+	var chunks []*IntSet
+	for _, set := range sets {
+		if set.chunks != nil {
+			chunks = append(chunks, set.chunks...)
+		} else {
+			chunks = append(chunks, set.splitChunks()...)
+		}
+	}
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].minValue < chunks[j].minValue })
+	result := make([]*IntSet, 0, len(chunks))
+	for i := 0; i < len(chunks); {
+		// the chunks sharing a key
+		j := i + 1
+		for j < len(chunks) && chunkKey(chunks[j].minValue) == chunkKey(chunks[i].minValue) {
+			j++
+		}
+		result = append(result, UnionAll(chunks[i:j]...))
+		i = j
+	}
+	return NewIntSet().setChunks(result)
+}
+
+// IntersectAll gets a new set of the members of all of the given sets, or the
+// empty set if there are none. Narrower sets are intersected first, stopping
+// once the result is empty. The given sets are only read.
+func IntersectAll(sets ...*IntSet) *IntSet {
+	switch len(sets) {
+	case 0:
+		return NewIntSet()
+	case 1:
+		return sets[0].Clone().SetAutoOptimize(false)
+	}
+	lo, hi := sets[0].minValue, sets[0].maxValue
+	flat, chunked := true, false
+	for _, set := range sets {
+		if set.IsEmpty() {
+			return NewIntSet()
+		}
+		lo, hi = rangeIntersection(lo, hi, set.minValue, set.maxValue)
+		flat = flat && set.vs == nil && set.chunks == nil
+		chunked = chunked || set.chunks != nil
+	}
+	if lo > hi {
+		return NewIntSet()
+	}
+	// sort by span rather than size, as counting would write to the sets
+	sorted := append([]*IntSet(nil), sets...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].maxValue-sorted[i].minValue < sorted[j].maxValue-sorted[j].minValue
+	})
+	if flat {
+		runs := []run{{lo, hi}}
+		for _, set := range sorted {
+			if runs = intersectRuns(runs, set.asRuns()); len(runs) == 0 {
+				break
+			}
+		}
+		return NewIntSet().setRuns(runs)
+	}
+	if chunked || hi-lo >= maxBitSetSpan {
+		// intersections only shrink, so fold from the smallest set
+		result := And(sorted[0], sorted[1])
+		for _, set := range sorted[2:] {
+			if result.IsEmpty() {
+				break
+			}
+			result = And(result, set)
+		}
+		return result
+	}
+	// start with every value in the range, then clear those missing from each set
+	result := &IntSet{minValue: lo, maxValue: hi, vsStart: lo &^ 0x3F, vs: make([]uint64, (hi>>6)-(lo>>6)+1)}
+	result.setBitRange(lo, hi)
+	for _, set := range sorted {
+		nonZero := false
+		if set.vs == nil {
+			for _, r := range differenceRuns([]run{{lo, hi}}, set.asRuns()) {
+				result.clearBitRange(r.minValue, r.maxValue)
+			}
+			nonZero = result.IntersectsRange(lo, hi)
+		} else {
+			start := (lo - result.vsStart) >> 6
+			end := (hi - result.vsStart) >> 6
+			offset := (result.vsStart - set.vsStart) >> 6
+			for i := start; i <= end; i++ {
+				result.vs[i] &= set.vs[i+offset]
+				nonZero = nonZero || result.vs[i] != 0
+			}
+		}
+		if !nonZero {
+			return NewIntSet()
+		}
+	}
+	result.trimBitSet()
+	return result
+}
@@ -0,0 +1,114 @@
+package bitset
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// postings gets n sets of varied forms over overlapping ranges
+func newPostings(seed int64, n int) []*IntSet {
+	r := rand.New(rand.NewSource(seed))
+	sets := make([]*IntSet, n)
+	for i := range sets {
+		base := uint(r.Intn(5000))
+		switch i % 3 {
+		case 0:
+			sets[i] = NewIntSetFromInterval(base, base+uint(r.Intn(20000)))
+		case 1:
+			values := make([]uint, 300)
+			for j := range values {
+				values[j] = base + uint(r.Intn(30000))
+			}
+			sets[i] = NewIntSetFromUInts(values)
+		default:
+			sets[i] = NewIntSetFromInterval(base, base+30000).RemoveRange(base+100, base+200).RemoveRange(base+5000, base+9000)
+		}
+	}
+	return sets
+}
+
+func sameMembers(a, b *IntSet) bool {
+	return a.Size() == b.Size() && a.CountIntersection(b) == a.Size()
+}
+
+func TestUnionAll(test *testing.T) {
+	for _, sets := range [][]*IntSet{
+		nil,
+		{NewIntSet(), NewIntSet()},
+		{newStepSet()},
+		{newRunsSet(), NewIntSetFromInterval(3000, 4000)},
+		newPostings(1, 100),
+		append(newPostings(2, 10), newChunkedSet()),
+		{newStepSet(), NewIntSetFromInterval(1<<30, 1<<30+5)},
+	} {
+		expected := NewIntSet()
+		for _, set := range sets {
+			expected = Or(expected, set)
+		}
+		result := UnionAll(sets...)
+		if !sameMembers(result, expected) {
+			test.Error("Bad union of", len(sets), "sets:", result.Size(), "should be", expected.Size())
+		}
+		if len(sets) > 0 && result == sets[0] {
+			test.Error("Bad union: should be a new set")
+		}
+	}
+}
+
+func TestIntersectAll(test *testing.T) {
+	for _, sets := range [][]*IntSet{
+		nil,
+		{newStepSet()},
+		{newStepSet(), NewIntSet()},
+		{newRunsSet(), NewIntSetFromInterval(100, 2000), NewIntSetFromInterval(150, 3000)},
+		newPostings(3, 6),
+		newPostings(4, 100),
+		{newChunkedSet(), newChunkedSet().RemoveRange(0, 1<<33)},
+		{newStepSet(), NewIntSetFromInterval(1000, 2000), NewIntSetFromUInts([]uint{1001, 1006, 1500, 1501})},
+	} {
+		var expected *IntSet
+		if len(sets) == 0 {
+			expected = NewIntSet()
+		} else {
+			expected = sets[0]
+			for _, set := range sets[1:] {
+				expected = And(expected, set)
+			}
+		}
+		result := IntersectAll(sets...)
+		if !sameMembers(result, expected) {
+			test.Error("Bad intersection of", len(sets), "sets:", result.Size(), "should be", expected.Size())
+		}
+	}
+}
+
+func TestIntersectAllReadOnly(test *testing.T) {
+	// an intersection with an interval leaves the count of a bitset out of date
+	uncounted := newStepSet().Intersection(NewIntSetFromInterval(1200, 2800))
+	if !uncounted.cardinalityInvalidated {
+		test.Fatal("Bad test: the count should be out of date")
+	}
+	IntersectAll(newStepSet(), uncounted, NewIntSetFromInterval(1000, 2000))
+	if !uncounted.cardinalityInvalidated {
+		test.Error("Bad intersection: counted the members of an input")
+	}
+}
+
+func BenchmarkUnionAll(b *testing.B) {
+	sets := newPostings(5, 300)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		UnionAll(sets...)
+	}
+}
+
+func BenchmarkUnionFold(b *testing.B) {
+	sets := newPostings(5, 300)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result := NewIntSet()
+		for _, set := range sets {
+			result.Union(set)
+		}
+	}
+}
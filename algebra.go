@@ -0,0 +1,195 @@
+package bitset
+
+// Or gets a new set of the members of either a or b, leaving both unchanged
+func Or(a, b *IntSet) *IntSet {
+	return pureOp(a, b, orOp)
+}
+
+// And gets a new set of the members of both a and b, leaving both unchanged
+func And(a, b *IntSet) *IntSet {
+	return pureOp(a, b, andOp)
+}
+
+// AndNot gets a new set of the members of a that are not in b, leaving both unchanged
+func AndNot(a, b *IntSet) *IntSet {
+	return pureOp(a, b, andNotOp)
+}
+
+// Xor gets a new set of the members of exactly one of a and b, leaving both unchanged
+func Xor(a, b *IntSet) *IntSet {
+	return pureOp(a, b, xorOp)
+}
+
+// setOp describes a binary operation for each form of set
+type setOp struct {
+	// bounds gets a range that any result lies within
+	bounds func(a, b *IntSet) (uint, uint)
+	runs   func(a, b []run) []run
+	word   func(x, y uint64) uint64
+	// bitRange applies the operation to the bits from lo to hi, as if they were all set in b
+	bitRange func(set *IntSet, lo, hi uint)
+	// whether members of only a or only b are kept
+	keepA, keepB bool
+	commutes     bool
+}
+
+var (
+	orOp = &setOp{
+		bounds:   (*IntSet).unionMinMax,
+		runs:     unionRuns,
+		word:     func(x, y uint64) uint64 { return x | y },
+		bitRange: (*IntSet).setBitRange,
+		keepA:    true, keepB: true, commutes: true,
+	}
+	andOp = &setOp{
+		bounds:   (*IntSet).intersectMinMax,
+		runs:     intersectRuns,
+		word:     func(x, y uint64) uint64 { return x & y },
+		commutes: true,
+	}
+	andNotOp = &setOp{
+		bounds:   func(a, b *IntSet) (uint, uint) { return a.minValue, a.maxValue },
+		runs:     differenceRuns,
+		word:     func(x, y uint64) uint64 { return x &^ y },
+		bitRange: (*IntSet).clearBitRange,
+		keepA:    true,
+	}
+	xorOp = &setOp{
+		bounds:   (*IntSet).unionMinMax,
+		runs:     symmetricDifferenceRuns,
+		word:     func(x, y uint64) uint64 { return x ^ y },
+		bitRange: (*IntSet).flipBitRange,
+		keepA:    true, keepB: true, commutes: true,
+	}
+)
+
+// pureOp applies an operation to a and b giving a new set, allocating only
+// the words within the bounds of the result
+func pureOp(a, b *IntSet, op *setOp) *IntSet {
+	lo, hi := op.bounds(a, b)
+	if lo > hi {
+		return NewIntSet()
+	}
+	if a.chunks != nil || b.chunks != nil || ((a.vs != nil || b.vs != nil) && hi-lo >= maxBitSetSpan) {
+		return pureChunkOp(a, b, op)
+	}
+	if a.vs == nil && b.vs == nil {
+		return NewIntSet().setRuns(op.runs(a.asRuns(), b.asRuns()))
+	}
+	if op.commutes && b.vs == nil {
+		// apply runs to the bitset rather than the other way around
+		a, b = b, a
+	}
+	result := &IntSet{minValue: lo, maxValue: hi, vsStart: lo &^ 0x3F, vs: make([]uint64, (hi>>6)-(lo>>6)+1)}
+	// start with the members of a within the range
+	if a.vs != nil {
+		start, end := result.vsStart, result.vsStart+uint(len(result.vs))<<6-1
+		minV, maxV := rangeIntersection(a.minValue, a.maxValue, start, end)
+		if minV <= maxV {
+			copy(result.vs[(minV-start)>>6:], a.vs[(minV-a.vsStart)>>6:(maxV-a.vsStart)>>6+1])
+		}
+		result.vs[0] &= AllBits << (lo & 0x3F)
+		result.vs[len(result.vs)-1] &= AllBits >> (63 - (hi & 0x3F))
+	} else {
+		for _, r := range a.asRuns() {
+			if minV, maxV := rangeIntersection(r.minValue, r.maxValue, lo, hi); minV <= maxV {
+				result.setBitRange(minV, maxV)
+			}
+		}
+	}
+	// then apply b a word or run at a time
+	if b.vs != nil {
+		minV, maxV := rangeIntersection(b.minValue, b.maxValue, result.vsStart, result.vsStart+uint(len(result.vs))<<6-1)
+		for i := range result.vs {
+			var w uint64
+			if v := result.vsStart + uint(i)<<6; minV <= maxV && v+63 >= minV && v <= maxV {
+				w = b.vs[(v-b.vsStart)>>6]
+			}
+			result.vs[i] = op.word(result.vs[i], w)
+		}
+	} else {
+		for _, r := range b.asRuns() {
+			if minV, maxV := rangeIntersection(r.minValue, r.maxValue, lo, hi); minV <= maxV {
+				op.bitRange(result, minV, maxV)
+			}
+		}
+	}
+	result.trimBitSet()
+	return result
+}
+
+// pureChunkOp applies an operation chunk by chunk where at least one set is
+// chunked, or the result is too large for a bitset
+func pureChunkOp(a, b *IntSet, op *setOp) *IntSet {
+	if op.commutes && a.chunks == nil {
+		a, b = b, a
+	}
+	var result []*IntSet
+	if a.chunks != nil && b.chunks == nil && !op.keepB {
+		// chunks stay within their range, so can work on the whole of b
+		for _, chunk := range a.chunks {
+			if !op.keepA && (chunk.maxValue < b.minValue || chunk.minValue > b.maxValue) {
+				continue
+			}
+			if chunk = pureOp(chunk, b, op); !chunk.IsEmpty() {
+				result = append(result, chunk)
+			}
+		}
+		return NewIntSet().setChunks(result)
+	}
+	if limit := uint(len(a.chunks) + len(b.chunks)); a.splitsWide(limit) || b.splitsWide(limit) {
+		// too many chunks would be split from a long interval or run
+		return NewIntSet().setRuns(op.runs(a.asRuns(), b.asRuns()))
+	}
+	// chunks split from a set are new, so can be used without a copy
+	own, others := a.chunks, b.chunks
+	copyOwn, copyOthers := own != nil, others != nil
+	if own == nil {
+		own = a.splitChunks()
+	}
+	if others == nil {
+		others = b.splitChunks()
+	}
+	i, j := 0, 0
+	for i < len(own) || j < len(others) {
+		if j == len(others) || (i < len(own) && chunkKey(own[i].minValue) < chunkKey(others[j].minValue)) {
+			if op.keepA {
+				result = append(result, copyIf(own[i], copyOwn))
+			}
+			i++
+		} else if i == len(own) || chunkKey(others[j].minValue) < chunkKey(own[i].minValue) {
+			if op.keepB {
+				result = append(result, copyIf(others[j], copyOthers))
+			}
+			j++
+		} else {
+			if chunk := pureOp(own[i], others[j], op); !chunk.IsEmpty() {
+				result = append(result, chunk)
+			}
+			i++
+			j++
+		}
+	}
+	return NewIntSet().setChunks(result)
+}
+
+// trimBitSet fits the bounds of a new bitset to its members, reallocating its
+// words if they cover more than the bounds, and counts the members
+func (set *IntSet) trimBitSet() {
+	set.fitBounds()
+	if set.vs != nil {
+		start := (set.minValue - set.vsStart) >> 6
+		if end := (set.maxValue-set.vsStart)>>6 + 1; end-start < uint(len(set.vs)) {
+			set.vs = append([]uint64(nil), set.vs[start:end]...)
+			set.vsStart += start << 6
+		}
+	}
+	set.countMembers()
+}
+
+func copyIf(set *IntSet, clone bool) *IntSet {
+	if clone {
+		return set.Clone()
+	}
+	return set
+}
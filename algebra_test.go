@@ -0,0 +1,80 @@
+package bitset
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAlgebra(test *testing.T) {
+	valuesA := newChunkedValues(2)
+	makers := newFormSets()
+	makers["far"] = func() *IntSet { return NewIntSetFromUInts([]uint{1 << 21, 1<<21 + 3, 1<<21 + 64}) }
+	makers["chunks"] = func() *IntSet { return NewIntSetFromUInts(valuesA) }
+	makers["chunks2"] = func() *IntSet {
+		return NewIntSetFromUInts(append(newStepSet().AsUints(), valuesA[:3000]...))
+	}
+	ops := []struct {
+		name string
+		op   func(a, b *IntSet) *IntSet
+		keep func(inA, inB bool) bool
+	}{
+		{"or", Or, func(inA, inB bool) bool { return inA || inB }},
+		{"and", And, func(inA, inB bool) bool { return inA && inB }},
+		{"andNot", AndNot, func(inA, inB bool) bool { return inA && !inB }},
+		{"xor", Xor, func(inA, inB bool) bool { return inA != inB }},
+	}
+	for nameA, makeA := range makers {
+		for nameB, makeB := range makers {
+			a, b := makeA(), makeB()
+			dataA, _ := a.MarshalBinary()
+			dataB, _ := b.MarshalBinary()
+			candidates := append(append([]uint{0, 1499, 2501, 1<<21 + 1}, a.AsUints()...), b.AsUints()...)
+			for _, o := range ops {
+				result := o.op(a, b)
+				count := uint(0)
+				seen := map[uint]bool{}
+				for _, v := range candidates {
+					expected := o.keep(a.Contains(v), b.Contains(v))
+					if result.Contains(v) != expected {
+						test.Error("Bad", o.name, "of", nameA, nameB, ":", v, "should be", expected)
+						break
+					}
+					if expected && !seen[v] {
+						seen[v] = true
+						count++
+					}
+				}
+				if result.Size() != count {
+					test.Error("Bad", o.name, "count of", nameA, nameB, ":", result.Size(), "should be", count)
+				}
+				if ok, first := result.GetFirstValue(); ok && (!result.Contains(first) || !result.Contains(result.maxValue)) {
+					test.Error("Bad", o.name, "bounds of", nameA, nameB, ":", result.minValue, result.maxValue)
+				}
+				if result.vs != nil && uint(len(result.vs)) > (result.maxValue>>6)-(result.minValue>>6)+1 {
+					test.Error("Bad", o.name, "allocation of", nameA, nameB, ":", len(result.vs), "words")
+				}
+			}
+			after, _ := a.MarshalBinary()
+			if !bytes.Equal(after, dataA) {
+				test.Error("Bad change to", nameA, "in operations with", nameB)
+			}
+			after, _ = b.MarshalBinary()
+			if !bytes.Equal(after, dataB) {
+				test.Error("Bad change to", nameB, "in operations with", nameA)
+			}
+		}
+	}
+}
+
+func TestSymmetricDifference(test *testing.T) {
+	set := newStepSet()
+	other := NewIntSetFromInterval(1500, 2500)
+	expected := Xor(set, other)
+	set.SymmetricDifference(other)
+	if set.Size() != expected.Size() || set.CountIntersection(expected) != expected.Size() {
+		test.Error("Bad symmetric difference:", set.String(), "should be", expected.String())
+	}
+	if other.Size() != 1001 {
+		test.Error("Bad change to the other set:", other.String())
+	}
+}
@@ -0,0 +1,142 @@
+package bitset
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// AtomicBitSet is a bitset over a fixed range of values that many goroutines
+// can change at once without locking. Words are laid out as in an IntSet,
+// from vsStart, and each is changed by compare and swap.
+type AtomicBitSet struct {
+	minValue uint
+	maxValue uint
+
+	vs      []uint64
+	vsStart uint
+
+	cardinality atomic.Int64
+
+	// counts of changes started and finished, which a snapshot checks did
+	// not move while it copied the words
+	started, finished atomic.Uint64
+	// snapshots waiting for changes to pause
+	pausing atomic.Int32
+}
+
+// NewAtomicBitSet gets an empty bitset that can hold values from min to max
+func NewAtomicBitSet(min, max uint) *AtomicBitSet {
+	start := (min >> 6) << 6
+	return &AtomicBitSet{minValue: min, maxValue: max, vs: make([]uint64, (max-start)/64+1), vsStart: start}
+}
+
+// Add adds x, returning whether it was not already a member. It panics if x
+// is outside the range of the set.
+func (set *AtomicBitSet) Add(x uint) bool {
+	word, bit := set.word(x)
+	if atomic.LoadUint64(word)&bit != 0 {
+		return false
+	}
+	set.begin()
+	defer set.finished.Add(1)
+	for {
+		old := atomic.LoadUint64(word)
+		if old&bit != 0 {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(word, old, old|bit) {
+			set.cardinality.Add(1)
+			return true
+		}
+	}
+}
+
+// Remove removes x, returning whether it was a member. It panics if x is
+// outside the range of the set.
+func (set *AtomicBitSet) Remove(x uint) bool {
+	word, bit := set.word(x)
+	if atomic.LoadUint64(word)&bit == 0 {
+		return false
+	}
+	set.begin()
+	defer set.finished.Add(1)
+	for {
+		old := atomic.LoadUint64(word)
+		if old&bit == 0 {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(word, old, old&^bit) {
+			set.cardinality.Add(-1)
+			return true
+		}
+	}
+}
+
+// Contains gets whether x is a member, which is false for any value outside
+// the range of the set
+func (set *AtomicBitSet) Contains(x uint) bool {
+	if x < set.minValue || x > set.maxValue {
+		return false
+	}
+	return atomic.LoadUint64(&set.vs[(x-set.vsStart)>>6])&(Bit<<(x&0x3F)) != 0
+}
+
+// Size gets the number of members
+func (set *AtomicBitSet) Size() uint {
+	return uint(set.cardinality.Load())
+}
+
+// Snapshot gets a new IntSet of the members at one moment. It copies the
+// words while no change is in progress, and if changes keep overlapping the
+// copy it has them wait briefly.
+func (set *AtomicBitSet) Snapshot() *IntSet {
+	vs := make([]uint64, len(set.vs))
+	for attempt := 0; ; attempt++ {
+		if attempt == 3 {
+			set.pausing.Add(1)
+			defer set.pausing.Add(-1)
+		}
+		started := set.started.Load()
+		if set.finished.Load() != started {
+			runtime.Gosched()
+			continue
+		}
+		for i := range vs {
+			vs[i] = atomic.LoadUint64(&set.vs[i])
+		}
+		if set.started.Load() == started {
+			break
+		}
+	}
+	snapshot := &IntSet{minValue: set.minValue, maxValue: set.maxValue, vs: vs, vsStart: set.vsStart}
+	snapshot.trimBitSet()
+	if snapshot.maxValue-snapshot.minValue >= maxBitSetSpan {
+		snapshot.promoteToChunks()
+	}
+	return snapshot
+}
+
+// word gets the word holding x and the bit for x within it
+func (set *AtomicBitSet) word(x uint) (*uint64, uint64) {
+	if x < set.minValue || x > set.maxValue {
+		panic(fmt.Sprintf("bitset: %d is outside the range %d to %d of an atomic bitset", x, set.minValue, set.maxValue))
+	}
+	return &set.vs[(x-set.vsStart)>>6], Bit << (x & 0x3F)
+}
+
+// begin counts a change as started, first waiting for any snapshot that has
+// asked changes to pause
+func (set *AtomicBitSet) begin() {
+	for {
+		for set.pausing.Load() != 0 {
+			runtime.Gosched()
+		}
+		set.started.Add(1)
+		if set.pausing.Load() == 0 {
+			return
+		}
+		// back out without changing anything, so the snapshot can finish
+		set.finished.Add(1)
+	}
+}
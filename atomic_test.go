@@ -0,0 +1,103 @@
+package bitset
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAtomicBitSet(test *testing.T) {
+	set := NewAtomicBitSet(1000, 5000)
+	if !set.Add(1000) || set.Add(1000) || !set.Add(5000) || !set.Add(2500) {
+		test.Error("Bad changes from add")
+	}
+	if !set.Remove(2500) || set.Remove(2500) || set.Remove(2501) {
+		test.Error("Bad changes from remove")
+	}
+	if !set.Contains(1000) || set.Contains(2500) || set.Contains(999) || set.Contains(1<<40) {
+		test.Error("Bad members:", set.Snapshot().String())
+	}
+	if set.Size() != 2 {
+		test.Error("Bad size:", set.Size(), "should be", 2)
+	}
+	snapshot := set.Snapshot()
+	if snapshot.Size() != 2 || !snapshot.Contains(1000) || !snapshot.Contains(5000) {
+		test.Error("Bad snapshot:", snapshot.String())
+	}
+	if NewAtomicBitSet(0, 1<<22).Snapshot().IsEmpty() != true {
+		test.Error("Bad snapshot of an empty set")
+	}
+	defer func() {
+		if recover() == nil {
+			test.Error("Bad add outside the range: should panic")
+		}
+	}()
+	set.Add(5001)
+}
+
+func TestAtomicBitSetConcurrent(test *testing.T) {
+	const workers, n = 8, 100000
+	set := NewAtomicBitSet(0, 1<<21)
+	var added [workers]int
+	var writing, reading sync.WaitGroup
+	start, done := make(chan struct{}), make(chan struct{})
+	for r := 0; r < 2; r++ {
+		reading.Add(1)
+		go func() {
+			defer reading.Done()
+			<-start
+			buf := make([]uint, 256)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				snapshot := set.Snapshot()
+				// each value past 1<<20 was added after the one 1<<20 below it
+				it := snapshot.Iterator()
+				it.AdvanceIfNeeded(1 << 20)
+				for k := it.NextMany(buf); k > 0; k = it.NextMany(buf) {
+					for _, v := range buf[:k] {
+						if !snapshot.Contains(v - 1<<20) {
+							test.Error("Bad snapshot: has", v, "without", v-1<<20)
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+	for w := 0; w < workers; w++ {
+		writing.Add(1)
+		go func(w int) {
+			defer writing.Done()
+			<-start
+			// workers overlap, and each adds v then v+1<<20 in order
+			for i := 0; i < n; i++ {
+				v := uint((i*7 + w*n/2) % (1 << 20))
+				if set.Add(v) {
+					added[w]++
+				}
+				if set.Add(v + 1<<20) {
+					added[w]++
+				}
+				if i%3 == 0 && set.Remove(v+1<<20) {
+					added[w]--
+				}
+			}
+		}(w)
+	}
+	close(start)
+	writing.Wait()
+	close(done)
+	reading.Wait()
+
+	total := 0
+	for _, count := range added {
+		total += count
+	}
+	snapshot := set.Snapshot()
+	if set.Size() != uint(total) || snapshot.Size() != uint(total) {
+		test.Error("Bad size after concurrent changes:", set.Size(), snapshot.Size(), "should be", total)
+	}
+}
@@ -19,8 +19,29 @@ type IntSet struct {
 	vs      []uint64 // nil for intervals
 	vsStart uint     //value offset, a multiple of 64
 
+	// or sorted, disjoint runs of values if there are few enough of them
+	runs []run // nil for intervals and bitsets
+
+	// or chunks of values sharing their high bits, for large sparse sets.
+	// Each chunk is a set in interval, run or bitset form.
+	chunks []*IntSet
+
+	// the number of members, exact unless invalidated
 	cardinalityInvalidated bool
 	cardinality            uint
+
+	// whether to call Optimize after binary operations
+	autoOptimize bool
+
+	// cumulative counts for Rank and Select, built when first needed
+	rankIndex []uint
+
+	// whether vs or runs may be shared with a clone, so must be copied
+	// before they are changed
+	shared bool
+	// whether this set is a clone sharing the vs or runs of another, so is
+	// split into chunks sharing a page each rather than copied when changed
+	borrowed bool
 }
 
 func NewIntSet() *IntSet {
@@ -34,29 +55,35 @@ func NewIntSetCapacity(capacity int) *IntSet {
 }
 
 func NewIntSetFromInts(values []int) *IntSet {
-	var max int
-	for _, v := range values {
-		if v > max {
-			max = v
-		}
-	}
-	set := IntSet{minValue: math.MaxUint, maxValue: 0, vs: make([]uint64, max/64+1), vsStart: 0, cardinalityInvalidated: false, cardinality: 0}
-	s := &set
-	for _, v := range values {
-		s.Add(uint(v))
+	uints := make([]uint, len(values))
+	for i, v := range values {
+		uints[i] = uint(v)
 	}
-	return s
+	return NewIntSetFromUInts(uints)
 }
 
 func NewIntSetFromUInts(values []uint) *IntSet {
+	var min uint = math.MaxUint
 	var max uint
 	for _, v := range values {
 		if v > max {
 			max = v
 		}
+		if v < min {
+			min = v
+		}
+	}
+	if len(values) == 0 || max-min >= maxBitSetSpan {
+		// too spread out for a bitset
+		s := NewIntSet()
+		for _, v := range values {
+			s.Add(v)
+		}
+		return s
 	}
 
-	set := IntSet{minValue: math.MaxUint, maxValue: 0, vs: make([]uint64, max/64+1), vsStart: 0, cardinalityInvalidated: false, cardinality: 0}
+	start := (min >> 6) << 6
+	set := IntSet{minValue: math.MaxUint, maxValue: 0, vs: make([]uint64, (max-start)/64+1), vsStart: start, cardinalityInvalidated: false, cardinality: 0}
 	s := &set
 	for _, v := range values {
 		s.Add(v)
@@ -69,15 +96,39 @@ func NewIntSetFromInterval(min, max uint) *IntSet {
 	return &set
 }
 
+// Clone gets a copy of this set, which can be changed without changing this
+// set. The two share their words or runs until one of them is changed. This
+// set then copies them and keeps its form, while the clone is split into
+// chunks sharing a page each, so that it copies only the pages it touches. A
+// chunked set is shared a chunk at a time by both.
 func (set *IntSet) Clone() *IntSet {
-	if set.vs == nil {
-		return NewIntSetFromInterval(set.minValue, set.maxValue)
+	if set.chunks != nil {
+		return set.cloneChunks()
+	}
+	clone := IntSet{minValue: set.minValue, maxValue: set.maxValue, vs: set.vs, vsStart: set.vsStart, runs: set.runs, cardinalityInvalidated: set.cardinalityInvalidated, cardinality: set.cardinality, autoOptimize: set.autoOptimize, rankIndex: set.rankIndex}
+	if set.vs != nil || set.runs != nil {
+		if !set.shared {
+			// checked first so that cloning a frozen set writes nothing
+			set.shared = true
+		}
+		clone.shared, clone.borrowed = true, true
 	}
-	clone := IntSet{minValue: set.minValue, maxValue: set.maxValue, vs: make([]uint64, len(set.vs)), vsStart: set.vsStart, cardinalityInvalidated: set.cardinalityInvalidated, cardinality: set.cardinality}
-	copy(clone.vs, set.vs)
 	return &clone
 }
 
+// unshare copies the words or runs this set may share with a clone, so that
+// they can be changed, or splits a clone into chunks each sharing a page of
+// them if they cover more than a page
+func (set *IntSet) unshare() {
+	borrowed := set.borrowed
+	set.shared, set.borrowed = false, false
+	if borrowed && set.splitPages() {
+		return
+	}
+	set.vs = append([]uint64(nil), set.vs...)
+	set.runs = append([]run(nil), set.runs...)
+}
+
 func (set *IntSet) promoteToBitSet() {
 	if set.vs != nil {
 		return
@@ -92,6 +143,13 @@ func (set *IntSet) promoteToBitSet() {
 	start := (set.minValue - set.vsStart) >> 6
 	end := (set.maxValue - set.vsStart) >> 6
 	set.vs = make([]uint64, end-start+5)
+	if set.runs != nil {
+		for _, r := range set.runs {
+			set.setBitRange(r.minValue, r.maxValue)
+		}
+		set.runs = nil
+		return
+	}
 	// set all values between start and end
 	for i := start + 1; i < end; i++ {
 		set.vs[i] = AllBits
@@ -107,6 +165,109 @@ func (set *IntSet) promoteToBitSet() {
 	}
 }
 
+// setBitRange sets the bits of all values from lo to hi, which must lie in the allocated words
+func (set *IntSet) setBitRange(lo, hi uint) {
+	start := (lo - set.vsStart) >> 6
+	end := (hi - set.vsStart) >> 6
+	startMask := AllBits << (lo & 0x3F)
+	endMask := AllBits >> (63 - (hi & 0x3F))
+	if start == end {
+		set.vs[start] |= startMask & endMask
+		return
+	}
+	set.vs[start] |= startMask
+	for i := start + 1; i < end; i++ {
+		set.vs[i] = AllBits
+	}
+	set.vs[end] |= endMask
+}
+
+// clearBitRange clears the bits of all values from lo to hi, which must lie in the allocated words
+func (set *IntSet) clearBitRange(lo, hi uint) {
+	start := (lo - set.vsStart) >> 6
+	end := (hi - set.vsStart) >> 6
+	startMask := AllBits << (lo & 0x3F)
+	endMask := AllBits >> (63 - (hi & 0x3F))
+	if start == end {
+		set.vs[start] &^= startMask & endMask
+		return
+	}
+	set.vs[start] &^= startMask
+	for i := start + 1; i < end; i++ {
+		set.vs[i] = 0
+	}
+	set.vs[end] &^= endMask
+}
+
+// flipBitRange flips the bits of all values from lo to hi, which must lie in the allocated words
+func (set *IntSet) flipBitRange(lo, hi uint) {
+	start := (lo - set.vsStart) >> 6
+	end := (hi - set.vsStart) >> 6
+	startMask := AllBits << (lo & 0x3F)
+	endMask := AllBits >> (63 - (hi & 0x3F))
+	if start == end {
+		set.vs[start] ^= startMask & endMask
+		return
+	}
+	set.vs[start] ^= startMask
+	for i := start + 1; i < end; i++ {
+		set.vs[i] = ^set.vs[i]
+	}
+	set.vs[end] ^= endMask
+}
+
+// rebaseBitSet moves the words of an empty bitset so that they hold x,
+// keeping their number
+func (set *IntSet) rebaseBitSet(x uint) {
+	if len(set.vs) == 0 {
+		set.vs = make([]uint64, 1)
+	}
+	n := uint(len(set.vs))
+	if x >= set.vsStart && (x-set.vsStart)>>6 < n {
+		return
+	}
+	// start at the word of x unless the words would run past the last value
+	set.vsStart = min(x&^0x3F, (math.MaxUint&^0x3F)-(n-1)<<6)
+}
+
+// growBitSet reallocates the words of a bitset if needed so that they cover
+// all values from minV to maxV. Each reallocation adds at least as many words
+// as there were, so adding values one at a time in either order is amortised.
+func (set *IntSet) growBitSet(minV, maxV uint) {
+	extra := min(uint(len(set.vs)), maxBitSetSpan>>6)
+	if minV < set.vsStart {
+		// reallocate the vs slice and update vsStart
+		newStart := minV >> 6
+		newStart -= min(newStart, extra)
+		newEnd := max((set.vsStart>>6)+uint(len(set.vs)), (maxV>>6)+1)
+		newVs := make([]uint64, newEnd-newStart)
+		copy(newVs[(set.vsStart>>6)-newStart:], set.vs)
+		set.vs = newVs
+		set.vsStart = newStart << 6
+	}
+	if end := (maxV - set.vsStart) >> 6; end >= uint(len(set.vs)) {
+		newVs := make([]uint64, max(end+1, uint(len(set.vs))+extra))
+		copy(newVs, set.vs)
+		set.vs = newVs
+	}
+}
+
+// countBitRange counts the set bits of values from lo to hi, which must lie in the allocated words
+func (set *IntSet) countBitRange(lo, hi uint) uint {
+	start := (lo - set.vsStart) >> 6
+	end := (hi - set.vsStart) >> 6
+	startMask := AllBits << (lo & 0x3F)
+	endMask := AllBits >> (63 - (hi & 0x3F))
+	if start == end {
+		return uint(bits.OnesCount64(set.vs[start] & startMask & endMask))
+	}
+	count := bits.OnesCount64(set.vs[start]&startMask) + bits.OnesCount64(set.vs[end]&endMask)
+	for i := start + 1; i < end; i++ {
+		count += bits.OnesCount64(set.vs[i])
+	}
+	return uint(count)
+}
+
 func (set *IntSet) intersectMinMax(other *IntSet) (uint, uint) {
 	minV := set.minValue
 	maxV := set.maxValue
@@ -135,7 +296,13 @@ func (set *IntSet) Contains(x uint) bool {
 	if x < set.minValue || x > set.maxValue {
 		return false
 	}
+	if set.chunks != nil {
+		return set.chunks[findChunk(set.chunks, x)].Contains(x)
+	}
 	if set.vs == nil {
+		if set.runs != nil {
+			return set.runs[findRun(set.runs, x)].minValue <= x
+		}
 		return true
 	}
 	index := (x - set.vsStart) >> 6
@@ -144,6 +311,13 @@ func (set *IntSet) Contains(x uint) bool {
 }
 
 func (set *IntSet) Add(x uint) *IntSet {
+	set.modified()
+	if set.chunks != nil {
+		return set.addToChunks(x)
+	}
+	if set.runs != nil {
+		return set.addToRuns(x)
+	}
 	// test for extending an interval
 	if set.vs == nil {
 		// test for adding to an empty interval
@@ -153,12 +327,12 @@ func (set *IntSet) Add(x uint) *IntSet {
 			set.cardinality = 1
 			return set
 		}
-		if x == set.minValue-1 {
+		if set.minValue > 0 && x == set.minValue-1 {
 			set.minValue = x
 			set.cardinality++
 			return set
 		}
-		if x == set.maxValue+1 {
+		if set.maxValue < math.MaxUint && x == set.maxValue+1 {
 			set.maxValue = x
 			set.cardinality++
 			return set
@@ -166,44 +340,46 @@ func (set *IntSet) Add(x uint) *IntSet {
 		if x >= set.minValue && x <= set.maxValue {
 			return set // already in the interval
 		}
-		// otherwise, promote to a bitset and keep going
-		set.promoteToBitSet()
+		// otherwise, split into runs
+		if x < set.minValue {
+			return set.setRuns([]run{{x, x}, {set.minValue, set.maxValue}})
+		}
+		return set.setRuns([]run{{set.minValue, set.maxValue}, {x, x}})
 	}
 
-	// note: x - set.vsStart could be negative, handle later
-	index := (x - set.vsStart) >> 6
 	// generate the bit within a uint
 	subIndex := x & 0x3F
 	bit := Bit << subIndex
 
-	if x < set.minValue {
+	if set.IsEmpty() {
+		// the words are all clear, so can be moved to wherever x is
+		set.rebaseBitSet(x)
 		set.minValue = x
-		if x < set.vsStart {
-			//TODO: re-allocate the vs slice and update vsStart
-			index = (x - set.vsStart) >> 6
-		}
-		set.vs[index] |= bit
-		set.cardinality++
-		if x > set.maxValue {
-			set.maxValue = x
-		}
+		set.maxValue = x
+		set.vs[(x-set.vsStart)>>6] |= bit
+		set.cardinality = 1
 		return set
 	}
 
-	if x > set.maxValue {
-		set.maxValue = x
-		// allocate more space at the end if necessary
-		if int(index) >= len(set.vs) {
-			newVs := make([]uint64, index+2)
-			copy(newVs, set.vs)
-			set.vs = newVs
-		}
+	if (x > set.maxValue && x-set.minValue >= maxBitSetSpan) || (x < set.minValue && set.maxValue-x >= maxBitSetSpan) {
+		// too far away to extend the bitset
+		set.promoteToChunks()
+		return set.addToChunks(x)
+	}
+
+	if x < set.minValue || x > set.maxValue {
+		// allocate more space at either end if necessary
+		minV, maxV := rangeUnion(set.minValue, set.maxValue, x, x)
+		set.growBitSet(minV, maxV)
+		set.minValue = minV
+		set.maxValue = maxV
 		// a fresh uint to write to
-		set.vs[index] |= bit
+		set.vs[(x-set.vsStart)>>6] |= bit
 		set.cardinality++
 		return set
 	}
 
+	index := (x - set.vsStart) >> 6
 	old := set.vs[index]
 	if (old & bit) != 0 { //already exists
 		return set
@@ -214,9 +390,16 @@ func (set *IntSet) Add(x uint) *IntSet {
 }
 
 func (set *IntSet) Remove(x uint) *IntSet {
+	set.modified()
 	if x < set.minValue || x > set.maxValue {
 		return set
 	}
+	if set.chunks != nil {
+		return set.removeFromChunks(x)
+	}
+	if set.runs != nil {
+		return set.removeFromRuns(x)
+	}
 	if set.vs == nil {
 		needPromote := true
 		if x == set.minValue {
@@ -234,10 +417,10 @@ func (set *IntSet) Remove(x uint) *IntSet {
 			return set
 		}
 		if needPromote {
-			set.promoteToBitSet()
-		} else {
-			return set
+			// split the interval in two
+			return set.setRuns([]run{{set.minValue, x - 1}, {x + 1, set.maxValue}})
 		}
+		return set
 	}
 
 	index := (x - set.vsStart) >> 6
@@ -249,6 +432,15 @@ func (set *IntSet) Remove(x uint) *IntSet {
 	}
 	set.vs[index] ^= bit
 	set.cardinality--
+	// move in a bound that was just removed
+	switch {
+	case x == set.minValue && x == set.maxValue:
+		return set.Clear()
+	case x == set.minValue:
+		_, set.minValue = set.GetNextValue(x)
+	case x == set.maxValue:
+		_, set.maxValue = set.GetPrevValue(x)
+	}
 	return set
 }
 
@@ -265,18 +457,16 @@ func (set *IntSet) IsEmpty() bool {
 }
 
 func (set *IntSet) Clear() *IntSet {
+	set.shared, set.borrowed = false, false // dropped rather than changed, so not copied
+	set.modified()
 	set.minValue = math.MaxUint
 	set.maxValue = 0
 	set.cardinality = 0
 	set.cardinalityInvalidated = false
-	if set.vs == nil {
-		return set
-	}
-	start := (set.minValue - set.vsStart) >> 6
-	end := (set.maxValue - set.vsStart) >> 6
-	for ; start <= end; start++ {
-		set.vs[start] = 0
-	}
+	set.vs = nil
+	set.vsStart = 0
+	set.runs = nil
+	set.chunks = nil
 	return set
 }
 
@@ -303,7 +493,22 @@ func (set *IntSet) GetNextValue(x uint) (bool, uint) {
 		// skip to the first value
 		x = set.minValue
 	}
+	if set.chunks != nil {
+		for i := findChunk(set.chunks, x); i < len(set.chunks); i++ {
+			if ok, v := set.chunks[i].GetNextValue(x - 1); ok {
+				return true, v
+			}
+		}
+		return false, 0
+	}
 	if set.vs == nil {
+		if set.runs != nil {
+			// skip to the start of the next run if in a gap
+			r := set.runs[findRun(set.runs, x)]
+			if r.minValue > x {
+				x = r.minValue
+			}
+		}
 		return true, x
 	}
 	//check for any bit later at this uint
@@ -328,14 +533,35 @@ func (set *IntSet) GetNextValue(x uint) (bool, uint) {
 }
 
 func (set *IntSet) GetPrevValue(x uint) (bool, uint) {
-	x--
-	if x < set.minValue {
+	if x <= set.minValue {
 		return false, 0
 	}
+	x--
 	if x > set.maxValue {
 		x = set.maxValue
 	}
+	if set.chunks != nil {
+		for i := findChunk(set.chunks, x); i >= 0; i-- {
+			if i == len(set.chunks) {
+				continue
+			}
+			if x >= set.chunks[i].maxValue {
+				return true, set.chunks[i].maxValue
+			}
+			if ok, v := set.chunks[i].GetPrevValue(x + 1); ok {
+				return true, v
+			}
+		}
+		return false, 0
+	}
 	if set.vs == nil {
+		if set.runs != nil {
+			// skip to the end of the previous run if in a gap
+			i := findRun(set.runs, x)
+			if set.runs[i].minValue > x {
+				x = set.runs[i-1].maxValue
+			}
+		}
 		return true, x
 	}
 	//check for any bit earlier at this uint
@@ -369,6 +595,12 @@ func (set *IntSet) GetPrevValue(x uint) (bool, uint) {
 }
 
 func (set *IntSet) CountIntersection(other *IntSet) uint {
+	if set.chunks != nil || other.chunks != nil {
+		return set.countIntersectionChunks(other)
+	}
+	if set.runs != nil || other.runs != nil {
+		return set.countIntersectionRuns(other)
+	}
 	if other.vs == nil && set.vs != nil {
 		// bit set : interval
 		return other.CountIntersection(set)
@@ -414,7 +646,7 @@ func (set *IntSet) CountIntersection(other *IntSet) uint {
 
 func (set *IntSet) CountIntersectionTo(other *IntSet, maxCount int) uint {
 	// TODO: speed this up repeating the loop from CountIntersection
-	if set.vs == nil {
+	if set.vs == nil || set.chunks != nil || other.chunks != nil {
 		return set.CountIntersection(other)
 	}
 	if other.vs == nil {
@@ -437,23 +669,17 @@ func (set *IntSet) CountIntersectionTo(other *IntSet, maxCount int) uint {
  * values that are also in other
  **/
 func (set *IntSet) Intersection(other *IntSet) *IntSet {
+	set.modified()
+	defer set.optimizeIfAuto()
+	if set.chunks != nil || other.chunks != nil {
+		return set.chunkOp(other, (*IntSet).Intersection, intersectRuns, false, false)
+	}
+	if set.runs != nil || other.runs != nil {
+		return set.intersectionRuns(other)
+	}
 	minV, maxV := set.intersectMinMax(other)
 	if minV > maxV {
-		if set.vs != nil {
-			// clear all bits
-			start := (set.minValue - set.vsStart) >> 6
-			end := (set.maxValue - set.vsStart) >> 6
-			for i := start; i <= end; i++ {
-				set.vs[i] = 0
-			}
-			set.cardinality = 0
-			set.cardinalityInvalidated = false
-			return set
-		}
-		set.minValue = math.MaxUint
-		set.maxValue = 0
-		set.cardinality = 0
-		return set
+		return set.Clear()
 	}
 	if set.vs == nil {
 		if other.vs == nil {
@@ -491,85 +717,78 @@ func (set *IntSet) Intersection(other *IntSet) *IntSet {
 			set.vs[start] = set.vs[start] & startMask
 			set.vs[end] = set.vs[end] & endMask
 		}
-		set.cardinality = 0
-		set.cardinalityInvalidated = false
+		set.minValue = minV
+		set.maxValue = maxV
+		set.cardinalityInvalidated = true
+		set.fitBounds()
 	} else {
-		// bit set : bit set intersection
+		// bit set : bit set intersection, counting the members that remain
+		count := 0
 		for i := start; i <= end; i++ {
 			set.vs[i] &= other.vs[i-start+otherStart]
+			count += bits.OnesCount64(set.vs[i])
 		}
-		// update min and max values
-		_, set.minValue = set.GetNextValue(minV - 1)
-		// TODO: update max value. Make a GetPrevID
-		set.cardinality = 0 // unknown
-		set.cardinalityInvalidated = true
+		if count == 0 {
+			return set.Clear()
+		}
+		// scan in from the shared range to the first and last members
+		set.minValue = minV
+		set.maxValue = maxV
+		set.fitBounds()
+		set.cardinality = uint(count)
+		set.cardinalityInvalidated = false
 	}
 	return set
 }
 
 // The union less the intersection
 func (set *IntSet) SymmetricDifference(other *IntSet) *IntSet {
+	defer set.optimizeIfAuto()
 	if set.minValue > other.maxValue || set.maxValue < other.minValue {
 		// no intersection, so return the union
 		return set.Union(other)
 	}
-	intersection := set.Clone().Intersection(other)
-	return set.Union(other).Difference(intersection)
+	return set.become(Xor(set, other))
 }
 
 func (set *IntSet) Difference(other *IntSet) *IntSet {
+	set.modified()
+	defer set.optimizeIfAuto()
 	minV, maxV := set.intersectMinMax(other)
 	if minV > maxV {
 		return set // no intersection
 	}
+	if set.chunks != nil || other.chunks != nil {
+		return set.chunkOp(other, (*IntSet).Difference, differenceRuns, true, false)
+	}
+	if set.runs != nil || other.runs != nil || (set.vs == nil && other.vs != nil) {
+		return set.differenceRuns(other, minV, maxV)
+	}
 	if set.vs == nil {
 		if other.vs == nil {
+			if other.minValue <= set.minValue && other.maxValue >= set.maxValue {
+				return set.Clear() // all removed
+			}
 			// check for shrinking interval
 			if other.maxValue <= set.maxValue && other.minValue <= set.minValue {
 				set.minValue = other.maxValue + 1
-				if set.IsEmpty() {
-					set.minValue = math.MaxUint
-					set.maxValue = 0
-					set.cardinality = 0
-					return set
-				}
 				set.cardinality = set.maxValue - set.minValue + 1
 				return set
 			}
 			if other.maxValue >= set.maxValue && other.minValue >= set.minValue {
 				set.maxValue = other.minValue - 1
-				if set.IsEmpty() {
-					set.minValue = math.MaxUint
-					set.maxValue = 0
-					set.cardinality = 0
-					return set
-				}
 				set.cardinality = set.maxValue - set.minValue + 1
 				return set
 			}
-			// must be split in two then, so promote and continue
-			set.promoteToBitSet()
+			// must be split in two then
+			return set.setRuns(differenceRuns(set.asRuns(), other.asRuns()))
 		}
 	}
 	if other.vs == nil {
 		// remove the interval from the bit set
-		start := (minV - set.vsStart) >> 6
-		end := (maxV - set.vsStart) >> 6
-
-		if start == (other.minValue-set.vsStart)>>6 {
-			// remove from the first uint, masked. A small min value removes more
-			set.vs[start] = set.vs[start] & (AllBits >> (63 - (other.minValue & 0x3F)))
-			start++
-		}
-		if end == (other.maxValue-set.vsStart)>>6 {
-			// remove from the last uint
-			set.vs[end] = set.vs[end] & (AllBits << (other.maxValue & 0x3F))
-			end--
-		}
-		for i := start; i <= end; i++ {
-			set.vs[i] = 0
-		}
-		set.cardinalityInvalidated = true
+		set.cardinality = set.Size() - set.countBitRange(minV, maxV)
+		set.clearBitRange(minV, maxV)
+		set.fitBounds()
 		return set
 	}
 
@@ -577,87 +796,72 @@ func (set *IntSet) Difference(other *IntSet) *IntSet {
 	start := (minV - set.vsStart) >> 6
 	end := (maxV - set.vsStart) >> 6
 	otherStart := ((minV - other.vsStart) >> 6)
+	count := set.Size()
 	for i := start; i <= end; i++ {
+		old := set.vs[i]
 		set.vs[i] &= (^other.vs[i-start+otherStart])
+		count -= uint(bits.OnesCount64(old) - bits.OnesCount64(set.vs[i]))
 	}
-	if minV == set.minValue {
-		// TODO: update min
-	}
-	if maxV == set.maxValue {
-		// TODO: update max
-	}
+	set.cardinality = count
+	set.fitBounds()
 	return set
 }
 
 func (set *IntSet) Union(other *IntSet) *IntSet {
-	minV, maxV := set.unionMinMax(other)
-	if minV > maxV {
-		// both are empty
+	set.modified()
+	defer set.optimizeIfAuto()
+	if other.IsEmpty() {
+		return set
+	}
+	if set.IsEmpty() {
+		set.become(other.Clone())
 		return set
 	}
+	minV, maxV := set.unionMinMax(other)
+	if set.chunks != nil || other.chunks != nil {
+		return set.chunkOp(other, (*IntSet).Union, unionRuns, true, true)
+	}
 	if set.vs == nil {
-		if maxV == set.maxValue && minV == set.minValue {
+		if set.runs == nil && maxV == set.maxValue && minV == set.minValue {
 			// nothing added
 			return set
 		}
 		if other.vs == nil {
-			set.maxValue = maxV
-			set.minValue = minV
-			set.cardinality = maxV - minV + 1
-			return set
+			// intervals and runs merge without a bitset
+			return set.setRuns(unionRuns(set.asRuns(), other.asRuns()))
 		}
-		// otherwise, promote to a bitset and keep going
-		set.promoteToBitSet()
 	}
-	if minV < set.vsStart {
-		// reallocate the vs slice and update vsStart
-		newStart := (minV >> 6)
-		if newStart > 0 {
-			newStart--
-		}
-		newEnd := (maxV >> 6) + 5
-		newVs := make([]uint64, newEnd-newStart+1)
-		copy(newVs[(set.vsStart>>6)-newStart:], set.vs)
-		set.vs = newVs
-		set.vsStart = newStart << 6
+	if maxV-minV >= maxBitSetSpan {
+		// too large a range for one bitset
+		return set.chunkOp(other, (*IntSet).Union, unionRuns, true, true)
 	}
-	if maxV > set.maxValue {
-		// possibly reallocate the vs slice and update maxValue
-		newEnd := (maxV - set.vsStart) >> 6
-		if newEnd >= uint(len(set.vs)) {
-			newVs := make([]uint64, newEnd+5)
-			copy(newVs, set.vs)
-			set.vs = newVs
-		}
+	if set.vs == nil {
+		// otherwise, promote to a bitset and keep going
+		set.promoteToBitSet()
 	}
+	set.growBitSet(minV, maxV)
+	// from here the count changes by the values newly set
+	count := set.Size()
 	if other.vs == nil {
-		// add the interval to the bit set
-		start := (other.minValue - set.vsStart) >> 6
-		end := (other.maxValue - set.vsStart) >> 6
-		startMask := AllBits << (other.minValue & 0x3F)
-		endMask := AllBits >> (63 - (other.maxValue & 0x3F))
-		if start == end {
-			set.vs[start] |= startMask & endMask
-		} else {
-			set.vs[start] |= startMask
-			set.vs[end] |= endMask
-		}
-		for i := start + 1; i < end; i++ {
-			set.vs[i] |= AllBits
+		// add the interval or runs to the bit set
+		for _, r := range other.asRuns() {
+			count += r.size() - set.countBitRange(r.minValue, r.maxValue)
+			set.setBitRange(r.minValue, r.maxValue)
 		}
+		set.cardinality = count
+		set.maxValue = maxV
+		set.minValue = minV
 		return set
 	}
 	start := (other.minValue - set.vsStart) >> 6
 	end := (other.maxValue - set.vsStart) >> 6
 	otherStart := ((other.minValue - other.vsStart) >> 6)
-	if end >= uint(len(set.vs)) {
-		newVs := make([]uint64, end+2)
-		copy(newVs, set.vs)
-		set.vs = newVs
-	}
 	for i := start; i <= end; i++ {
+		old := set.vs[i]
 		set.vs[i] |= other.vs[i-start+otherStart]
+		count += uint(bits.OnesCount64(set.vs[i]) - bits.OnesCount64(old))
 	}
+	set.cardinality = count
 	set.maxValue = maxV
 	set.minValue = minV
 	return set
@@ -680,6 +884,11 @@ func (set *IntSet) AsUints() []uint {
 }
 
 func (set *IntSet) countMembers() uint {
+	if set.chunks != nil {
+		set.cardinality = set.countChunks()
+		set.cardinalityInvalidated = false
+		return set.cardinality
+	}
 	if set.vs == nil {
 		return set.cardinality
 	}
@@ -694,8 +903,8 @@ func (set *IntSet) countMembers() uint {
 	return set.cardinality
 }
 
-// Size gets an upper bound on the size of this set. After a call to CountMembers this value
-// is accurate until elements are removed.
+// Size gets the number of members of this set. Operations keep the count
+// exact as they change words, or else mark it to be recounted here.
 func (set *IntSet) Size() uint {
 	if set.cardinalityInvalidated {
 		set.countMembers()
@@ -710,7 +919,7 @@ func (set *IntSet) String() string {
 	}
 	s := "{"
 	// longer intervals, print as a range
-	if set.vs == nil && set.maxValue-set.minValue > 10 {
+	if set.vs == nil && set.runs == nil && set.chunks == nil && set.maxValue-set.minValue > 10 {
 		return fmt.Sprint(s, set.minValue, "..", set.maxValue, "}")
 	}
 	first := true
@@ -1,7 +1,11 @@
 package bitset
 
 import (
+	"bytes"
+	"math"
 	"math/bits"
+	"math/rand"
+	"slices"
 	"testing"
 )
 
@@ -395,3 +399,374 @@ func TestAddAllBitSetInterval(test *testing.T) {
 		test.Error("Bad count:", setA.Size(), "should be", len(members))
 	}
 }
+
+// modelValue picks a value near the others, or far enough away to need chunks
+func modelValue(r *rand.Rand) uint {
+	if r.Intn(20) == 0 {
+		return 1<<22 + uint(r.Intn(5000))
+	}
+	return uint(r.Intn(5000))
+}
+
+// newModelSet builds a set of a random form, and its members
+func newModelSet(r *rand.Rand) (*IntSet, map[uint]bool) {
+	model := map[uint]bool{}
+	var set *IntSet
+	switch r.Intn(4) {
+	case 0:
+		lo := modelValue(r)
+		hi := lo + uint(r.Intn(3000))
+		set = NewIntSetFromInterval(lo, hi)
+		for v := lo; v <= hi; v++ {
+			model[v] = true
+		}
+	case 1:
+		set = NewIntSet()
+		for i := 0; i < 5; i++ {
+			lo := modelValue(r)
+			hi := lo + uint(r.Intn(100))
+			set.AddRange(lo, hi)
+			for v := lo; v <= hi; v++ {
+				model[v] = true
+			}
+		}
+	default:
+		values := make([]uint, r.Intn(400))
+		for i := range values {
+			values[i] = modelValue(r)
+			model[values[i]] = true
+		}
+		set = NewIntSetFromUInts(values)
+	}
+	return set, model
+}
+
+func TestCardinalityModel(test *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 300; round++ {
+		set, model := newModelSet(r)
+		set.SetAutoOptimize(round%2 == 0)
+		for step := 0; step < 50; step++ {
+			x := modelValue(r)
+			hi := x + uint(r.Intn(200))
+			other, otherModel := newModelSet(r)
+			name := ""
+			switch r.Intn(11) {
+			case 0:
+				name = "add"
+				set.Add(x)
+				model[x] = true
+			case 1:
+				name = "remove"
+				set.Remove(x)
+				delete(model, x)
+			case 2:
+				name = "add range"
+				set.AddRange(x, hi)
+				for v := x; v <= hi; v++ {
+					model[v] = true
+				}
+			case 3:
+				name = "remove range"
+				set.RemoveRange(x, hi)
+				for v := x; v <= hi; v++ {
+					delete(model, v)
+				}
+			case 4:
+				name = "flip range"
+				set.FlipRange(x, hi)
+				for v := x; v <= hi; v++ {
+					if model[v] {
+						delete(model, v)
+					} else {
+						model[v] = true
+					}
+				}
+			case 5:
+				name = "union"
+				set.Union(other)
+				for v := range otherModel {
+					model[v] = true
+				}
+			case 6:
+				name = "intersection"
+				set.Intersection(other)
+				for v := range model {
+					if !otherModel[v] {
+						delete(model, v)
+					}
+				}
+			case 7:
+				name = "difference"
+				set.Difference(other)
+				for v := range otherModel {
+					delete(model, v)
+				}
+			case 8:
+				name = "symmetric difference"
+				set.SymmetricDifference(other)
+				for v := range otherModel {
+					if model[v] {
+						delete(model, v)
+					} else {
+						model[v] = true
+					}
+				}
+			case 9:
+				// carry on with a clone, after changing the original
+				name = "clone"
+				clone := set.Clone()
+				set.FlipRange(x, hi)
+				set.Union(other)
+				set = clone
+			default:
+				name = "optimize"
+				set.Optimize()
+			}
+			if set.Size() != uint(len(model)) {
+				test.Error("Bad size after", name, "in round", round, "step", step, ":", set.Size(), "should be", len(model))
+				break
+			}
+			if set.IsEmpty() != (len(model) == 0) {
+				test.Error("Bad emptiness after", name, "in round", round, "step", step, ":", set.IsEmpty())
+				break
+			}
+			if ok, first := set.GetFirstValue(); ok && !model[first] {
+				test.Error("Bad first value after", name, "in round", round, "step", step, ":", first)
+			}
+			if ok, last := set.GetLastValue(); ok && !model[last] {
+				test.Error("Bad last value after", name, "in round", round, "step", step, ":", last)
+			}
+			count := 0
+			for v := range set.All() {
+				if !model[v] {
+					test.Error("Bad member after", name, "in round", round, "step", step, ":", v)
+					break
+				}
+				count++
+			}
+			if count != len(model) {
+				test.Error("Bad iteration after", name, "in round", round, "step", step, ":", count, "should be", len(model))
+				break
+			}
+		}
+	}
+}
+
+func TestTightBounds(test *testing.T) {
+	set := newStepSet()
+	set.Difference(NewIntSetFromUInts([]uint{1001, 1006, 2991, 2996}))
+	if _, first := set.GetFirstValue(); first != 1011 {
+		test.Error("Bad first value after difference:", first, "should be", 1011)
+	}
+	if _, last := set.GetLastValue(); last != 2986 {
+		test.Error("Bad last value after difference:", last, "should be", 2986)
+	}
+	set = newStepSet()
+	set.Intersection(NewIntSetFromUInts([]uint{500, 1006, 1500, 1501, 2000, 2001, 4000}))
+	if _, first := set.GetFirstValue(); first != 1006 {
+		test.Error("Bad first value after intersection:", first, "should be", 1006)
+	}
+	if _, last := set.GetLastValue(); last != 2001 {
+		test.Error("Bad last value after intersection:", last, "should be", 2001)
+	}
+	set.Intersection(NewIntSetFromUInts([]uint{1007, 1500, 1502, 2002}))
+	if !set.IsEmpty() || set.Size() != 0 {
+		test.Error("Bad empty intersection:", set.String())
+	}
+	set = NewIntSetFromUInts([]uint{100, 200, 300})
+	set.Remove(200)
+	set.Remove(300)
+	if _, last := set.GetLastValue(); last != 100 {
+		test.Error("Bad last value after remove:", last, "should be", 100)
+	}
+	set.Remove(100)
+	if !set.IsEmpty() {
+		test.Error("Bad remove of the last member:", set.String())
+	}
+	set = NewIntSetFromUInts([]uint{0, 5, 70})
+	if ok, v := set.GetPrevValue(0); ok {
+		test.Error("Bad value before 0:", v)
+	}
+}
+
+func TestAddDescending(test *testing.T) {
+	set := NewIntSetFromUInts([]uint{1 << 19})
+	count := uint(1)
+	reallocations := 0
+	for v := uint(1<<19 - 1); v >= 1<<18; v -= 3 {
+		n := len(set.vs)
+		set.Add(v)
+		count++
+		if len(set.vs) != n {
+			reallocations++
+		}
+	}
+	set.Add(0)
+	if set.Size() != count+1 {
+		test.Error("Bad size after adding in descending order:", set.Size(), "should be", count+1)
+	}
+	for v := uint(1 << 18); v < 1<<19; v++ {
+		if expected := (1<<19-1-v)%3 == 0; set.Contains(v) != expected {
+			test.Error("Bad member after adding in descending order:", v, "should be", expected)
+			break
+		}
+	}
+	if !set.Contains(0) || set.Contains(1) {
+		test.Error("Bad lowest member after adding in descending order")
+	}
+	if reallocations > 20 {
+		test.Error("Bad number of reallocations adding in descending order:", reallocations)
+	}
+	// intervals at either end of the values do not extend around to the other
+	if low := NewIntSetFromInterval(0, 5).Add(math.MaxUint); low.IsEmpty() || low.Size() != 7 || low.Contains(math.MaxUint-1) {
+		test.Error("Bad interval after adding the largest value:", low.String())
+	}
+	if high := NewIntSetFromInterval(math.MaxUint-5, math.MaxUint).Add(0); high.IsEmpty() || high.Size() != 7 || high.Contains(1) {
+		test.Error("Bad interval after adding zero:", high.String())
+	}
+}
+
+func TestClone(test *testing.T) {
+	makers := newFormSets()
+	for name, makeSet := range makers {
+		for _, changeClone := range []bool{false, true} {
+			set := makeSet()
+			clone := set.Clone()
+			data, _ := set.MarshalBinary()
+			if set.vs != nil && &clone.vs[0] != &set.vs[0] {
+				test.Error("Bad clone of", name, ": words were copied")
+			}
+			changed, kept := set, clone
+			if changeClone {
+				changed, kept = clone, set
+			}
+			changed.Add(1700)
+			changed.Remove(1006)
+			changed.FlipRange(2000, 2300)
+			if changed.Contains(1006) || !changed.Contains(1700) {
+				test.Error("Bad change to", name, "after a clone")
+			}
+			after, _ := kept.MarshalBinary()
+			if !bytes.Equal(after, data) {
+				test.Error("Bad copy of", name, ": a change to one copy reached the other, changing the clone:", changeClone)
+			}
+			if kept.vs != nil && changed.vs != nil && &kept.vs[0] == &changed.vs[0] {
+				test.Error("Bad clone of", name, ": words still shared after a change")
+			}
+			kept.Clear()
+			if changed.IsEmpty() {
+				test.Error("Bad clear of", name, ": clearing one copy cleared the other")
+			}
+		}
+	}
+}
+
+func TestCloneChunks(test *testing.T) {
+	set := newChunkedSet()
+	clone := set.Clone()
+	for i, chunk := range clone.chunks {
+		if chunk == set.chunks[i] {
+			test.Error("Bad clone of chunks: chunk", i, "is shared")
+		}
+	}
+	// a change to one chunk copies only the words of that chunk
+	_, last := set.GetLastValue()
+	clone.Remove(last)
+	end := len(set.chunks) - 1
+	for i, chunk := range clone.chunks[:end] {
+		if chunk.vs != nil && &chunk.vs[0] != &set.chunks[i].vs[0] {
+			test.Error("Bad copy of chunk", i, "which was not changed")
+		}
+	}
+	if !set.Contains(last) || clone.Contains(last) {
+		test.Error("Bad change to a cloned chunk")
+	}
+}
+
+func TestClonePages(test *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	bitset := NewIntSet()
+	for i := 0; i < 20000; i++ {
+		bitset.Add(uint(r.Intn(1 << 19)))
+	}
+	runs := NewIntSet()
+	for v := uint(0); v < 1<<20-20; v += 256 {
+		runs.AddRange(v, v+9)
+	}
+	runs.Optimize()
+	// runs crossing between chunks are copied with the chunks they cross
+	crossing := NewIntSet()
+	for v := uint(0); v < 1<<20-20; v += 200 {
+		crossing.AddRange(v, v+9)
+	}
+	crossing.Optimize()
+	if bitset.vs == nil || runs.runs == nil || crossing.runs == nil {
+		test.Error("Bad test sets: should be a bitset and runs")
+	}
+	for name, set := range map[string]*IntSet{"bitset": bitset, "runs": runs, "crossing": crossing} {
+		// where each word and run of the original is held
+		words, pieces := map[*uint64]bool{}, map[*run]bool{}
+		for i := range set.vs {
+			words[&set.vs[i]] = true
+		}
+		for i := range set.runs {
+			pieces[&set.runs[i]] = true
+		}
+		members := set.AsUints()
+		clone := set.Clone()
+		clone.Add(1<<19 + 100)
+		clone.Remove(members[0])
+		if clone.chunks == nil {
+			test.Error("Bad form of a changed clone of", name, ": should be split into chunks")
+		}
+		copied := 0
+		for _, chunk := range clone.chunks {
+			switch {
+			case chunk.vs != nil && !words[&chunk.vs[0]]:
+				copied++
+			case chunk.runs != nil && !pieces[&chunk.runs[0]]:
+				copied++
+			}
+		}
+		if copied > 2 && name != "crossing" {
+			test.Error("Bad copy of a clone of", name, ":", copied, "of", len(clone.chunks), "pages copied")
+		}
+		if !slices.Equal(set.AsUints(), members) || !clone.Contains(1<<19+100) || clone.Contains(members[0]) || clone.Size() != uint(len(members)) {
+			test.Error("Bad original", name, "after changing a clone")
+		}
+		// changes to the original in turn leave the clone alone
+		expected := clone.AsUints()
+		set.RemoveRange(0, 1<<20)
+		if got := clone.AsUints(); !slices.Equal(got, expected) {
+			test.Error("Bad clone of", name, "after clearing the original:", len(got), "should be", len(expected))
+		}
+	}
+	// a clone that is dropped leaves the original in its own form
+	set := NewIntSetFromInterval(0, 200000).Remove(5)
+	set.promoteToBitSet()
+	_ = set.Clone()
+	set.Add(5)
+	if set.vs == nil || set.chunks != nil || set.Size() != 200001 {
+		test.Error("Bad form of an original changed after a clone:", len(set.chunks), "chunks")
+	}
+}
+
+func TestAddToEmptyBitSet(test *testing.T) {
+	for _, x := range []uint{0, 50, 4000000000, math.MaxUint - 8, math.MaxUint} {
+		set := NewIntSetCapacity(100)
+		set.Add(x)
+		if set.Size() != 1 || !set.Contains(x) || len(set.vs) > 2 {
+			test.Error("Bad add of", x, "to an empty bitset:", set.Size(), "members in", len(set.vs), "words")
+		}
+		set.Add(x ^ 1)
+		if set.Size() != 2 || !set.Contains(x^1) || len(set.vs) > 2 {
+			test.Error("Bad add of", x^1, "after", x, ":", set.Size(), "members in", len(set.vs), "words")
+		}
+	}
+	// a far value still switches to chunks once there is a member
+	set := NewIntSetCapacity(100).Add(5).Add(4000000000)
+	if set.chunks == nil || set.Size() != 2 || !set.Contains(5) || !set.Contains(4000000000) {
+		test.Error("Bad far add to a bitset:", set.Size(), "should be 2 in chunks")
+	}
+}
@@ -0,0 +1,435 @@
+package bitset
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	// chunked sets group values by their high bits, each chunk covering 2^chunkBits values
+	chunkBits = 16
+	chunkMask = 1<<chunkBits - 1
+
+	// bitsets never span more values than this, larger sets are chunked instead
+	maxBitSetSpan = 1 << 20
+	// sets spanning more than maxBitSetSpan values are chunked beyond this many runs
+	maxRuns = 1 << 12
+
+	// a changed clone copies the words or runs it shares a chunk at a time,
+	// each chunk being a page of at most this many words
+	pageWords = 1 << (chunkBits - 6)
+)
+
+func chunkKey(x uint) uint {
+	return x >> chunkBits
+}
+
+// findChunk gets the index of the first chunk with a key at or after that of x
+func findChunk(chunks []*IntSet, x uint) int {
+	key := chunkKey(x)
+	return sort.Search(len(chunks), func(i int) bool { return chunkKey(chunks[i].maxValue) >= key })
+}
+
+// fitBounds shrinks the min and max values of a bitset to its first and last
+// members. Empty bitsets revert to empty intervals.
+func (set *IntSet) fitBounds() {
+	if set.vs == nil || set.IsEmpty() {
+		return
+	}
+	start := (set.minValue - set.vsStart) >> 6
+	end := (set.maxValue - set.vsStart) >> 6
+	for start <= end && set.vs[start] == 0 {
+		start++
+	}
+	if start > end {
+		set.Clear()
+		return
+	}
+	for set.vs[end] == 0 {
+		end--
+	}
+	set.minValue = set.vsStart + (start << 6) + uint(bits.TrailingZeros64(set.vs[start]))
+	set.maxValue = set.vsStart + (end << 6) + 63 - uint(bits.LeadingZeros64(set.vs[end]))
+}
+
+// promoteToChunks switches a set to the chunked form
+func (set *IntSet) promoteToChunks() {
+	if set.chunks != nil {
+		return
+	}
+	chunks := set.splitChunks()
+	if len(chunks) == 0 {
+		// a bitset with no members left
+		set.Clear()
+		return
+	}
+	set.chunks = chunks
+	set.vs = nil
+	set.vsStart = 0
+	set.runs = nil
+}
+
+// splitPages switches a clone of a bitset or run set that shares more than a
+// page of words or runs to chunks holding slices of them, getting whether it
+// did. The chunks are marked as shared, so each copies only its own page when
+// changed.
+func (set *IntSet) splitPages() bool {
+	if set.IsEmpty() || chunkKey(set.minValue) == chunkKey(set.maxValue) {
+		return false
+	}
+	var chunks []*IntSet
+	if n := uint(len(set.vs)); n > pageWords {
+		chunks = set.bitSetPages()
+	} else if n := uint(len(set.runs)); 2*n > pageWords && !set.splitsWide(2*n) {
+		chunks = set.runPages()
+	}
+	if len(chunks) < 2 {
+		return false
+	}
+	count, invalidated := set.cardinality, set.cardinalityInvalidated
+	set.setChunks(chunks)
+	set.cardinality, set.cardinalityInvalidated = count, invalidated
+	return true
+}
+
+// bitSetPages gets a chunk for each key holding members of a bitset, sharing
+// the words of the bitset
+func (set *IntSet) bitSetPages() []*IntSet {
+	var chunks []*IntSet
+	lo := set.minValue
+	for {
+		hi := min(chunkKey(lo)<<chunkBits|chunkMask, set.maxValue)
+		start := (lo - set.vsStart) >> 6
+		end := (hi - set.vsStart) >> 6
+		chunk := &IntSet{minValue: lo, maxValue: hi, vs: set.vs[start : end+1 : end+1], vsStart: set.vsStart + start<<6, cardinalityInvalidated: true, shared: true}
+		if chunk.fitBounds(); !chunk.IsEmpty() {
+			chunks = append(chunks, chunk)
+		}
+		if hi == set.maxValue {
+			return chunks
+		}
+		lo = hi + 1
+	}
+}
+
+// runPages gets a chunk for each key holding members of a run set, sharing
+// the runs of the set except where a run crosses between keys
+func (set *IntSet) runPages() []*IntSet {
+	var chunks []*IntSet
+	runs := set.runs
+	lo := set.minValue
+	for i := 0; i < len(runs); {
+		lo = max(lo, runs[i].minValue)
+		keyEnd := chunkKey(lo)<<chunkBits | chunkMask
+		j := i + 1
+		for j < len(runs) && runs[j].minValue <= keyEnd {
+			j++
+		}
+		// runs i to j-1 hold the members of this key
+		pieces, shared := runs[i:j:j], true
+		if pieces[0].minValue < lo || pieces[len(pieces)-1].maxValue > keyEnd {
+			pieces, shared = append([]run(nil), pieces...), false
+			pieces[0].minValue = lo
+			pieces[len(pieces)-1].maxValue = min(pieces[len(pieces)-1].maxValue, keyEnd)
+		}
+		if len(pieces) == 1 {
+			chunks = append(chunks, NewIntSetFromInterval(pieces[0].minValue, pieces[0].maxValue))
+		} else {
+			chunk := &IntSet{minValue: pieces[0].minValue, maxValue: pieces[len(pieces)-1].maxValue, runs: pieces, shared: shared}
+			for _, r := range pieces {
+				chunk.cardinality += r.size()
+			}
+			chunks = append(chunks, chunk)
+		}
+		if runs[j-1].maxValue > keyEnd {
+			// the last run carries on into the next key
+			i = j - 1
+			lo = keyEnd + 1
+		} else {
+			i = j
+		}
+	}
+	return chunks
+}
+
+// runKeys counts the chunk keys holding members of the given runs, stopping
+// once there are more than limit
+func runKeys(runs []run, limit uint) uint {
+	var count uint
+	last := ^uint(0)
+	for _, r := range runs {
+		first := chunkKey(r.minValue)
+		if first == last {
+			first++ // shares a chunk with the run before
+		}
+		if last = chunkKey(r.maxValue); last >= first {
+			count += last - first + 1
+		}
+		if count > limit {
+			break
+		}
+	}
+	return count
+}
+
+// splitsWide gets whether splitting a set that is not chunked would make more
+// than limit chunks, as a long interval or run would with a chunk for every
+// key it covers
+func (set *IntSet) splitsWide(limit uint) bool {
+	if set.chunks != nil || set.vs != nil {
+		return false // chunks are already split, and bitsets span few keys
+	}
+	return runKeys(set.asRuns(), limit) > limit
+}
+
+// wideChunks gets whether splitting the sets that are not chunked would make
+// more chunks than the chunked sets have between them
+func wideChunks(sets []*IntSet) bool {
+	var limit uint
+	for _, set := range sets {
+		limit += uint(len(set.chunks))
+	}
+	for _, set := range sets {
+		if set.splitsWide(limit) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitChunks gets the members of a set that is not chunked as a list of
+// new chunks, each holding the members sharing a key
+func (set *IntSet) splitChunks() []*IntSet {
+	if set.IsEmpty() {
+		return nil
+	}
+	var chunks []*IntSet
+	if set.vs != nil {
+		lo := set.minValue
+		for {
+			hi := chunkKey(lo)<<chunkBits | chunkMask
+			if hi > set.maxValue {
+				hi = set.maxValue
+			}
+			if chunk := set.bitSetChunk(lo, hi); chunk != nil {
+				chunks = append(chunks, chunk)
+			}
+			if hi == set.maxValue {
+				return chunks
+			}
+			lo = hi + 1
+		}
+	}
+	var pieces []run
+	key := chunkKey(set.minValue)
+	for _, r := range set.asRuns() {
+		for {
+			if chunkKey(r.minValue) != key {
+				chunks = append(chunks, NewIntSet().setRuns(pieces))
+				pieces = nil
+				key = chunkKey(r.minValue)
+			}
+			if chunkKey(r.maxValue) == key {
+				pieces = append(pieces, r)
+				break
+			}
+			// split the run at the end of this chunk
+			end := key<<chunkBits | chunkMask
+			pieces = append(pieces, run{r.minValue, end})
+			r.minValue = end + 1
+		}
+	}
+	return append(chunks, NewIntSet().setRuns(pieces))
+}
+
+// bitSetChunk copies the members of a bitset from lo to hi into a new bitset,
+// or gets nil if there are none
+func (set *IntSet) bitSetChunk(lo, hi uint) *IntSet {
+	start := (lo - set.vsStart) >> 6
+	end := (hi - set.vsStart) >> 6
+	chunk := IntSet{minValue: lo, maxValue: hi, vs: make([]uint64, end-start+1), vsStart: set.vsStart + (start << 6), cardinalityInvalidated: true}
+	copy(chunk.vs, set.vs[start:end+1])
+	chunk.vs[0] &= AllBits << (lo & 0x3F)
+	chunk.vs[end-start] &= AllBits >> (63 - (hi & 0x3F))
+	chunk.fitBounds()
+	if chunk.IsEmpty() {
+		return nil
+	}
+	return &chunk
+}
+
+// setChunks replaces the members of this set with the given non-empty chunks,
+// taking on the form of the chunk if there is only one
+func (set *IntSet) setChunks(chunks []*IntSet) *IntSet {
+	switch len(chunks) {
+	case 0:
+		return set.Clear()
+	case 1:
+		return set.become(chunks[0])
+	}
+	set.vs = nil
+	set.vsStart = 0
+	set.runs = nil
+	set.shared, set.borrowed = false, false
+	set.chunks = chunks
+	set.minValue = chunks[0].minValue
+	set.maxValue = chunks[len(chunks)-1].maxValue
+	set.cardinalityInvalidated = true
+	return set
+}
+
+func (set *IntSet) addToChunks(x uint) *IntSet {
+	i := findChunk(set.chunks, x)
+	if i < len(set.chunks) && chunkKey(set.chunks[i].minValue) == chunkKey(x) {
+		chunk := set.chunks[i]
+		n := chunk.Size()
+		chunk.Add(x)
+		set.cardinality += chunk.Size() - n
+	} else {
+		set.chunks = append(set.chunks, nil)
+		copy(set.chunks[i+1:], set.chunks[i:])
+		set.chunks[i] = NewIntSetFromInterval(x, x)
+		set.cardinality++
+	}
+	if x < set.minValue {
+		set.minValue = x
+	}
+	if x > set.maxValue {
+		set.maxValue = x
+	}
+	return set
+}
+
+func (set *IntSet) removeFromChunks(x uint) *IntSet {
+	i := findChunk(set.chunks, x)
+	if i == len(set.chunks) || !set.chunks[i].Contains(x) {
+		return set
+	}
+	chunk := set.chunks[i]
+	chunk.Remove(x)
+	chunk.fitBounds()
+	set.cardinality--
+	if chunk.IsEmpty() {
+		set.chunks = append(set.chunks[:i], set.chunks[i+1:]...)
+		if len(set.chunks) < 2 {
+			return set.setChunks(set.chunks)
+		}
+	}
+	set.minValue = set.chunks[0].minValue
+	set.maxValue = set.chunks[len(set.chunks)-1].maxValue
+	return set
+}
+
+func (set *IntSet) countChunks() uint {
+	var count uint
+	for _, chunk := range set.chunks {
+		count += chunk.Size()
+	}
+	return count
+}
+
+func (set *IntSet) chunkRuns() []run {
+	var runs []run
+	for _, chunk := range set.chunks {
+		for _, r := range chunk.asRuns() {
+			runs = appendRun(runs, r)
+		}
+	}
+	return runs
+}
+
+func (set *IntSet) cloneChunks() *IntSet {
+	clone := IntSet{minValue: set.minValue, maxValue: set.maxValue, chunks: make([]*IntSet, len(set.chunks)), cardinalityInvalidated: set.cardinalityInvalidated, cardinality: set.cardinality, autoOptimize: set.autoOptimize, rankIndex: set.rankIndex}
+	for i, chunk := range set.chunks {
+		clone.chunks[i] = chunk.Clone()
+	}
+	return &clone
+}
+
+// countIntersectionChunks counts the intersection where at least one set is chunked
+func (set *IntSet) countIntersectionChunks(other *IntSet) uint {
+	if set.chunks == nil {
+		set, other = other, set
+	}
+	var count uint
+	if other.chunks == nil {
+		for i := findChunk(set.chunks, other.minValue); i < len(set.chunks) && set.chunks[i].minValue <= other.maxValue; i++ {
+			count += set.chunks[i].CountIntersection(other)
+		}
+		return count
+	}
+	i, j := 0, 0
+	for i < len(set.chunks) && j < len(other.chunks) {
+		a, b := chunkKey(set.chunks[i].minValue), chunkKey(other.chunks[j].minValue)
+		if a == b {
+			count += set.chunks[i].CountIntersection(other.chunks[j])
+		}
+		if a <= b {
+			i++
+		}
+		if b <= a {
+			j++
+		}
+	}
+	return count
+}
+
+// chunkOp applies a binary operation chunk by chunk where at least one set is
+// chunked, or the result is too large for a bitset. Chunks with no counterpart
+// are kept if keepOwn, or copied from the other set if addOther. Where a set
+// would split into more chunks than there are already, as a long interval
+// would, the operation is applied to the runs of the sets instead.
+func (set *IntSet) chunkOp(other *IntSet, op func(a, b *IntSet) *IntSet, runsOp func(a, b []run) []run, keepOwn, addOther bool) *IntSet {
+	if limit := uint(len(set.chunks) + len(other.chunks)); set.splitsWide(limit) || (addOther && other.splitsWide(limit)) {
+		return set.setRuns(runsOp(set.asRuns(), other.asRuns()))
+	}
+	own := set.chunks
+	if own == nil {
+		own = set.splitChunks()
+	}
+	result := make([]*IntSet, 0, len(own))
+	if other.chunks == nil && !addOther {
+		// chunks stay within their range, so can work on the whole other set
+		for _, chunk := range own {
+			chunk = op(chunk, other)
+			chunk.fitBounds()
+			if !chunk.IsEmpty() {
+				result = append(result, chunk)
+			}
+		}
+		return set.setChunks(result)
+	}
+	others := other.chunks
+	copyOthers := true
+	if others == nil {
+		others = other.splitChunks()
+		copyOthers = false
+	}
+	i, j := 0, 0
+	for i < len(own) || j < len(others) {
+		if j == len(others) || (i < len(own) && chunkKey(own[i].minValue) < chunkKey(others[j].minValue)) {
+			if keepOwn {
+				result = append(result, own[i])
+			}
+			i++
+		} else if i == len(own) || chunkKey(others[j].minValue) < chunkKey(own[i].minValue) {
+			if addOther {
+				if copyOthers {
+					result = append(result, others[j].Clone())
+				} else {
+					result = append(result, others[j])
+				}
+			}
+			j++
+		} else {
+			chunk := op(own[i], others[j])
+			chunk.fitBounds()
+			if !chunk.IsEmpty() {
+				result = append(result, chunk)
+			}
+			i++
+			j++
+		}
+	}
+	return set.setChunks(result)
+}
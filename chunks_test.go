@@ -0,0 +1,160 @@
+package bitset
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// sorted clusters of values spread over a large range, enough to need chunks
+func newChunkedValues(seed int64) []uint {
+	r := rand.New(rand.NewSource(seed))
+	values := make([]uint, 0, 20000)
+	for c := 0; c < 40; c++ {
+		base := uint(r.Int63n(1 << 34))
+		for i := 0; i < 500; i++ {
+			values = append(values, base+uint(r.Intn(20000)))
+		}
+	}
+	return sortedUnique(values)
+}
+
+func sortedUnique(values []uint) []uint {
+	sorted := append([]uint(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	unique := sorted[:0]
+	for i, v := range sorted {
+		if i == 0 || v != sorted[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+func TestChunksSparse(test *testing.T) {
+	set := NewIntSetFromUInts([]uint{5, 4000000000})
+	if set.vs != nil || set.chunks != nil {
+		test.Error("Bad representation: should be two runs")
+	}
+	if set.Size() != 2 || !set.Contains(5) || !set.Contains(4000000000) || set.Contains(6) {
+		test.Error("Bad members:", set.String(), "should be {5,4000000000}")
+	}
+	// large values close together are still a bitset
+	set = NewIntSetFromUInts([]uint{1 << 40, 1<<40 + 3, 1<<40 + 64})
+	if set.vs == nil || set.Size() != 3 {
+		test.Error("Bad representation: should be a bitset")
+	}
+}
+
+func TestChunksAdd(test *testing.T) {
+	values := newChunkedValues(1)
+	set := NewIntSet()
+	for _, v := range values {
+		set.Add(v)
+	}
+	if set.chunks == nil {
+		test.Error("Bad representation: should be chunked")
+	}
+	members := sortedUnique(values)
+	if set.Size() != uint(len(members)) {
+		test.Error("Bad count:", set.Size(), "should be", len(members))
+	}
+	i := 0
+	for ok, v := set.GetFirstValue(); ok; ok, v = set.GetNextValue(v) {
+		if v != members[i] {
+			test.Error("Bad ID:", v, "should be", members[i])
+			break
+		}
+		i++
+	}
+	i = len(members) - 1
+	for ok, v := set.GetLastValue(); ok; ok, v = set.GetPrevValue(v) {
+		if v != members[i] {
+			test.Error("Bad ID:", v, "should be", members[i], "at index", i)
+			break
+		}
+		i--
+	}
+	for _, v := range members[:len(members)/2] {
+		set.Remove(v)
+	}
+	if set.Size() != uint(len(members)-len(members)/2) {
+		test.Error("Bad count after remove:", set.Size(), "should be", len(members)-len(members)/2)
+	}
+	if set.Contains(members[0]) || !set.Contains(members[len(members)-1]) {
+		test.Error("Bad members after remove")
+	}
+	if ok, first := set.GetFirstValue(); !ok || first != members[len(members)/2] {
+		test.Error("Bad first value:", first, "should be", members[len(members)/2])
+	}
+}
+
+func TestChunksExtendBitSet(test *testing.T) {
+	set := newStepSet()
+	set.Add(1 << 40)
+	if set.vs != nil || set.chunks == nil {
+		test.Error("Bad representation: should be chunked")
+	}
+	if set.Size() != 401 || !set.Contains(1<<40) || !set.Contains(1006) || set.Contains(1007) {
+		test.Error("Bad members:", set.String())
+	}
+}
+
+func TestChunksBinaryOps(test *testing.T) {
+	valuesA := newChunkedValues(2)
+	valuesB := sortedUnique(append(newChunkedValues(3), valuesA[:5000]...))
+	// each form is placed among the chunks, so that every pair overlaps
+	makers := newFormSets()
+	makers["chunks"] = func() *IntSet { return NewIntSetFromUInts(valuesA) }
+	makers["chunksB"] = func() *IntSet { return NewIntSetFromUInts(valuesB) }
+	makers["interval"] = func() *IntSet { return NewIntSetFromInterval(valuesA[0]-5000, valuesA[0]+5000) }
+	makers["bitset"] = func() *IntSet { return NewIntSetFromUInts(valuesA[:500]) }
+	makers["runs"] = func() *IntSet {
+		return NewIntSetFromInterval(valuesA[600]-1<<22, valuesA[600]+1<<22).Remove(valuesA[600]).Remove(valuesA[610])
+	}
+	candidates := append(append([]uint{valuesA[600] - 1<<22 - 1, valuesA[600] + 1<<22 + 1}, valuesA...), valuesB...)
+	for _, v := range candidates[:2000] {
+		candidates = append(candidates, v-1, v+1)
+	}
+	ops := []struct {
+		name string
+		op   func(a, b *IntSet) *IntSet
+		keep func(inA, inB bool) bool
+	}{
+		{"union", (*IntSet).Union, func(inA, inB bool) bool { return inA || inB }},
+		{"intersection", (*IntSet).Intersection, func(inA, inB bool) bool { return inA && inB }},
+		{"difference", (*IntSet).Difference, func(inA, inB bool) bool { return inA && !inB }},
+	}
+	for nameA, makeA := range makers {
+		for nameB, makeB := range makers {
+			a, b := makeA(), makeB()
+			if a.chunks == nil && b.chunks == nil {
+				continue
+			}
+			count := a.Clone().Intersection(b).Size()
+			if found := a.CountIntersection(b); found != count {
+				test.Error("Bad intersection count for", nameA, nameB, ":", found, "should be", count)
+			}
+			for _, o := range ops {
+				result := o.op(makeA(), b)
+				for _, v := range candidates {
+					expected := o.keep(a.Contains(v), b.Contains(v))
+					if result.Contains(v) != expected {
+						test.Error("Bad", o.name, "of", nameA, nameB, ":", v, "should be", expected)
+						break
+					}
+				}
+				size := a.Size() + b.Size() - count
+				switch o.name {
+				case "intersection":
+					size = count
+				case "difference":
+					size = a.Size() - count
+				}
+				if result.Size() != size {
+					test.Error("Bad", o.name, "count of", nameA, nameB, ":", result.Size(), "should be", size)
+				}
+			}
+		}
+	}
+}
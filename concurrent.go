@@ -0,0 +1,345 @@
+package bitset
+
+import (
+	"io"
+	"iter"
+	"sync"
+)
+
+// ConcurrentIntSet is a set that is safe to use from many goroutines. Reads
+// share a read lock and never write to the set, so any counts and indexes
+// they need are filled in under the write lock first. Methods taking another
+// set read it without locking, so a ConcurrentIntSet should be passed as a
+// Snapshot.
+type ConcurrentIntSet struct {
+	mu  sync.RWMutex
+	set *IntSet
+	// which of needCounts, needIndexes and needShared are up to date
+	settled int
+}
+
+// what a read needs brought up to date under the write lock before it can run
+// under the read lock
+const (
+	needCounts = 1 << iota
+	needIndexes
+	// the set is marked as sharing its words, so cloning it writes nothing
+	needShared
+)
+
+// NewConcurrentIntSet wraps a set, which should not be used directly afterwards
+func NewConcurrentIntSet(set *IntSet) *ConcurrentIntSet {
+	return &ConcurrentIntSet{set: set}
+}
+
+// settle counts the members of this set and each of its chunks, and builds
+// their rank indexes if index, so that reading them later writes nothing
+func (set *IntSet) settle(index bool) {
+	for _, chunk := range set.chunks {
+		chunk.settle(index)
+	}
+	set.Size()
+	if index && (set.vs != nil || set.runs != nil || set.chunks != nil) {
+		set.buildRankIndex()
+	}
+}
+
+// markShared marks the words and runs of this set and each of its chunks as
+// shared, so that cloning it later writes nothing
+func (set *IntSet) markShared() {
+	for _, chunk := range set.chunks {
+		chunk.markShared()
+	}
+	if set.vs != nil || set.runs != nil {
+		set.shared = true
+	}
+}
+
+// read calls fn under the read lock, first settling the set under the write
+// lock if fn needs anything that is out of date
+func (c *ConcurrentIntSet) read(needs int, fn func(set *IntSet)) {
+	for {
+		c.mu.RLock()
+		if c.settled&needs == needs {
+			defer c.mu.RUnlock()
+			fn(c.set)
+			return
+		}
+		c.mu.RUnlock()
+		c.mu.Lock()
+		if missing := needs &^ c.settled; missing&(needCounts|needIndexes) != 0 {
+			c.set.settle(missing&needIndexes != 0)
+			c.settled |= needCounts | missing&needIndexes
+		}
+		if needs&needShared != 0 {
+			c.set.markShared()
+			c.settled |= needShared
+		}
+		c.mu.Unlock()
+	}
+}
+
+// write calls fn under the write lock, after which everything needs settling
+func (c *ConcurrentIntSet) write(fn func(set *IntSet)) *ConcurrentIntSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c.set)
+	c.settled = 0
+	return c
+}
+
+// writeErr is write for methods that can fail
+func (c *ConcurrentIntSet) writeErr(fn func(set *IntSet) error) error {
+	var err error
+	c.write(func(set *IntSet) { err = fn(set) })
+	return err
+}
+
+// Snapshot gets a copy of the members of this set as it is now, sharing its
+// words until either changes
+func (c *ConcurrentIntSet) Snapshot() *IntSet {
+	var snapshot *IntSet
+	c.read(needShared, func(set *IntSet) { snapshot = set.Clone() })
+	return snapshot
+}
+
+func (c *ConcurrentIntSet) Clone() *ConcurrentIntSet {
+	return NewConcurrentIntSet(c.Snapshot())
+}
+
+func (c *ConcurrentIntSet) Contains(x uint) (ok bool) {
+	c.read(0, func(set *IntSet) { ok = set.Contains(x) })
+	return ok
+}
+
+func (c *ConcurrentIntSet) IsEmpty() (ok bool) {
+	c.read(0, func(set *IntSet) { ok = set.IsEmpty() })
+	return ok
+}
+
+func (c *ConcurrentIntSet) IsSubsetOf(other *IntSet) (ok bool) {
+	c.read(needCounts, func(set *IntSet) { ok = set.IsSubsetOf(other) })
+	return ok
+}
+
+func (c *ConcurrentIntSet) IsDisjointFrom(other *IntSet) (ok bool) {
+	c.read(0, func(set *IntSet) { ok = set.IsDisjointFrom(other) })
+	return ok
+}
+
+func (c *ConcurrentIntSet) Size() (n uint) {
+	c.read(needCounts, func(set *IntSet) { n = set.Size() })
+	return n
+}
+
+func (c *ConcurrentIntSet) GetFirstValue() (ok bool, v uint) {
+	c.read(0, func(set *IntSet) { ok, v = set.GetFirstValue() })
+	return ok, v
+}
+
+func (c *ConcurrentIntSet) GetLastValue() (ok bool, v uint) {
+	c.read(0, func(set *IntSet) { ok, v = set.GetLastValue() })
+	return ok, v
+}
+
+func (c *ConcurrentIntSet) GetNextValue(x uint) (ok bool, v uint) {
+	c.read(0, func(set *IntSet) { ok, v = set.GetNextValue(x) })
+	return ok, v
+}
+
+func (c *ConcurrentIntSet) GetPrevValue(x uint) (ok bool, v uint) {
+	c.read(0, func(set *IntSet) { ok, v = set.GetPrevValue(x) })
+	return ok, v
+}
+
+func (c *ConcurrentIntSet) Rank(x uint) (n uint) {
+	c.read(needCounts|needIndexes, func(set *IntSet) { n = set.Rank(x) })
+	return n
+}
+
+func (c *ConcurrentIntSet) Select(i uint) (v uint, ok bool) {
+	c.read(needCounts|needIndexes, func(set *IntSet) { v, ok = set.Select(i) })
+	return v, ok
+}
+
+func (c *ConcurrentIntSet) CountIntersection(other *IntSet) (n uint) {
+	c.read(0, func(set *IntSet) { n = set.CountIntersection(other) })
+	return n
+}
+
+func (c *ConcurrentIntSet) CountIntersectionTo(other *IntSet, maxCount int) (n uint) {
+	c.read(0, func(set *IntSet) { n = set.CountIntersectionTo(other, maxCount) })
+	return n
+}
+
+func (c *ConcurrentIntSet) CountUnion(other *IntSet) (n uint) {
+	c.read(0, func(set *IntSet) { n = set.CountUnion(other) })
+	return n
+}
+
+func (c *ConcurrentIntSet) CountDifference(other *IntSet) (n uint) {
+	c.read(0, func(set *IntSet) { n = set.CountDifference(other) })
+	return n
+}
+
+func (c *ConcurrentIntSet) CountSymmetricDifference(other *IntSet) (n uint) {
+	c.read(0, func(set *IntSet) { n = set.CountSymmetricDifference(other) })
+	return n
+}
+
+func (c *ConcurrentIntSet) CountRange(lo, hi uint) (n uint) {
+	c.read(0, func(set *IntSet) { n = set.CountRange(lo, hi) })
+	return n
+}
+
+func (c *ConcurrentIntSet) ContainsRange(lo, hi uint) (ok bool) {
+	c.read(0, func(set *IntSet) { ok = set.ContainsRange(lo, hi) })
+	return ok
+}
+
+func (c *ConcurrentIntSet) IntersectsRange(lo, hi uint) (ok bool) {
+	c.read(0, func(set *IntSet) { ok = set.IntersectsRange(lo, hi) })
+	return ok
+}
+
+func (c *ConcurrentIntSet) AsInts() (ids []int) {
+	c.read(needCounts, func(set *IntSet) { ids = set.AsInts() })
+	return ids
+}
+
+func (c *ConcurrentIntSet) AsUints() (ids []uint) {
+	c.read(needCounts, func(set *IntSet) { ids = set.AsUints() })
+	return ids
+}
+
+// All iterates over a snapshot taken when the loop starts, so the set may be
+// changed within the loop
+func (c *ConcurrentIntSet) All() iter.Seq[uint] {
+	return func(yield func(uint) bool) {
+		c.Snapshot().forward(yield)
+	}
+}
+
+// Backward iterates over a snapshot from the last member to the first
+func (c *ConcurrentIntSet) Backward() iter.Seq[uint] {
+	return func(yield func(uint) bool) {
+		c.Snapshot().backward(yield)
+	}
+}
+
+// Runs iterates over the runs of a snapshot
+func (c *ConcurrentIntSet) Runs() iter.Seq2[uint, uint] {
+	return func(yield func(uint, uint) bool) {
+		c.Snapshot().Runs()(yield)
+	}
+}
+
+// Iterator gets an iterator over a snapshot of this set
+func (c *ConcurrentIntSet) Iterator() *Iterator {
+	return c.Snapshot().Iterator()
+}
+
+func (c *ConcurrentIntSet) String() (s string) {
+	c.read(0, func(set *IntSet) { s = set.String() })
+	return s
+}
+
+func (c *ConcurrentIntSet) Format() (s string) {
+	c.read(0, func(set *IntSet) { s = set.Format() })
+	return s
+}
+
+func (c *ConcurrentIntSet) MarshalBinary() (data []byte, err error) {
+	c.read(needCounts, func(set *IntSet) { data, err = set.MarshalBinary() })
+	return data, err
+}
+
+func (c *ConcurrentIntSet) WriteTo(w io.Writer) (n int64, err error) {
+	c.read(needCounts, func(set *IntSet) { n, err = set.WriteTo(w) })
+	return n, err
+}
+
+func (c *ConcurrentIntSet) ExportRoaring(w io.Writer) (err error) {
+	c.read(needCounts, func(set *IntSet) { err = set.ExportRoaring(w) })
+	return err
+}
+
+func (c *ConcurrentIntSet) MarshalJSON() (data []byte, err error) {
+	c.read(0, func(set *IntSet) { data, err = set.MarshalJSON() })
+	return data, err
+}
+
+func (c *ConcurrentIntSet) MarshalText() (data []byte, err error) {
+	c.read(0, func(set *IntSet) { data, err = set.MarshalText() })
+	return data, err
+}
+
+func (c *ConcurrentIntSet) Add(x uint) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.Add(x) })
+}
+
+func (c *ConcurrentIntSet) Remove(x uint) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.Remove(x) })
+}
+
+func (c *ConcurrentIntSet) Clear() *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.Clear() })
+}
+
+func (c *ConcurrentIntSet) AddRange(lo, hi uint) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.AddRange(lo, hi) })
+}
+
+func (c *ConcurrentIntSet) RemoveRange(lo, hi uint) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.RemoveRange(lo, hi) })
+}
+
+func (c *ConcurrentIntSet) FlipRange(lo, hi uint) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.FlipRange(lo, hi) })
+}
+
+func (c *ConcurrentIntSet) Union(other *IntSet) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.Union(other) })
+}
+
+func (c *ConcurrentIntSet) Intersection(other *IntSet) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.Intersection(other) })
+}
+
+func (c *ConcurrentIntSet) Difference(other *IntSet) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.Difference(other) })
+}
+
+func (c *ConcurrentIntSet) SymmetricDifference(other *IntSet) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.SymmetricDifference(other) })
+}
+
+func (c *ConcurrentIntSet) Optimize() *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.Optimize() })
+}
+
+func (c *ConcurrentIntSet) SetAutoOptimize(on bool) *ConcurrentIntSet {
+	return c.write(func(set *IntSet) { set.SetAutoOptimize(on) })
+}
+
+func (c *ConcurrentIntSet) UnmarshalBinary(data []byte) error {
+	return c.writeErr(func(set *IntSet) error { return set.UnmarshalBinary(data) })
+}
+
+func (c *ConcurrentIntSet) ReadFrom(r io.Reader) (n int64, err error) {
+	c.write(func(set *IntSet) { n, err = set.ReadFrom(r) })
+	return n, err
+}
+
+func (c *ConcurrentIntSet) UnmarshalJSON(data []byte) error {
+	return c.writeErr(func(set *IntSet) error { return set.UnmarshalJSON(data) })
+}
+
+func (c *ConcurrentIntSet) UnmarshalText(text []byte) error {
+	return c.writeErr(func(set *IntSet) error { return set.UnmarshalText(text) })
+}
+
+// Set parses a list of values and ranges into this set, as a flag.Value
+func (c *ConcurrentIntSet) Set(s string) error {
+	return c.writeErr(func(set *IntSet) error { return set.Set(s) })
+}
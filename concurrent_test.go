@@ -0,0 +1,94 @@
+package bitset
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentIntSet(test *testing.T) {
+	set := NewConcurrentIntSet(NewIntSetFromInterval(0, 999))
+	const writers, readers, n = 4, 8, 3000
+	var writing, reading sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writing.Add(1)
+		go func(w uint) {
+			defer writing.Done()
+			// each writer has its own range, far enough apart to need chunks
+			base := (w + 1) << 22
+			for i := uint(0); i < n; i++ {
+				set.Add(base + 3*i)
+				if i%10 == 0 {
+					set.Remove(base + 3*i)
+				}
+				if i%500 == 0 {
+					set.Union(NewIntSetFromInterval(base+1<<20, base+1<<20+i))
+					set.RemoveRange(base+1<<20+i/2, base+1<<20+i)
+				}
+			}
+		}(uint(w))
+	}
+	done := make(chan struct{})
+	for r := 0; r < readers; r++ {
+		reading.Add(1)
+		go func(r int) {
+			defer reading.Done()
+			other := NewIntSetFromInterval(500, 1<<23)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				size := set.Size()
+				if !set.Contains(500) || set.Rank(999) != 1000 {
+					test.Error("Bad members of the unchanged range")
+					return
+				}
+				if v, ok := set.Select(size / 2); ok && !set.Contains(v) && size == set.Size() {
+					test.Error("Bad selected member:", v)
+					return
+				}
+				set.CountIntersection(other)
+				set.IsSubsetOf(other)
+				switch r % 3 {
+				case 0:
+					count := 0
+					for range set.All() {
+						count++
+					}
+				case 1:
+					set.MarshalBinary()
+				default:
+					set.AsUints()
+				}
+			}
+		}(r)
+	}
+	writing.Wait()
+	close(done)
+	reading.Wait()
+
+	expected := NewIntSetFromInterval(0, 999)
+	for w := uint(0); w < writers; w++ {
+		base := (w + 1) << 22
+		for i := uint(0); i < n; i++ {
+			if i%10 != 0 {
+				expected.Add(base + 3*i)
+			}
+		}
+		expected.AddRange(base+1<<20, base+1<<20+1250-1)
+	}
+	snapshot := set.Snapshot()
+	if !sameMembers(snapshot, expected) {
+		test.Error("Bad members after concurrent changes:", snapshot.Size(), "should be", expected.Size())
+	}
+	if set.Rank(^uint(0)) != expected.Size() {
+		test.Error("Bad rank of all members:", set.Rank(^uint(0)), "should be", expected.Size())
+	}
+	data, _ := set.MarshalBinary()
+	expectedData, _ := snapshot.MarshalBinary()
+	if !bytes.Equal(data, expectedData) {
+		test.Error("Bad encoding of a concurrent set")
+	}
+}
@@ -0,0 +1,415 @@
+package bitset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// binaryVersion is written as the first byte of the binary encoding. Version 1
+// had no checksum, and can still be read.
+const binaryVersion = 2
+
+// the form of a set in the binary encoding
+const (
+	binaryEmpty byte = iota
+	binaryInterval
+	binaryRuns
+	binaryBitSet
+	binaryChunks
+)
+
+var (
+	// ErrTruncated is returned when encoded data ends part way through a set
+	ErrTruncated = errors.New("bitset: truncated data")
+	// ErrCorrupt is returned when encoded data does not describe a valid set
+	ErrCorrupt = errors.New("bitset: corrupt data")
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. The encoding is a version
+// byte then the form of the set: intervals as their min value and length,
+// runs as the gaps and lengths between them, and bitsets as vsStart plus the
+// words between the min and max values. Chunked sets encode each chunk in turn.
+// A CRC-32 checksum of all of this follows.
+func (set *IntSet) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := set.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the
+// members of this set with those encoded by MarshalBinary. The set takes on
+// the same representation as the one encoded.
+func (set *IntSet) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	if _, err := set.ReadFrom(r); err != nil {
+		if err == io.EOF {
+			return ErrTruncated
+		}
+		return err
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: %d bytes after the end of the set", ErrCorrupt, r.Len())
+	}
+	return nil
+}
+
+// WriteTo implements io.WriterTo, writing the same encoding as MarshalBinary
+// without building it in memory first
+func (set *IntSet) WriteTo(w io.Writer) (int64, error) {
+	e := binaryEncoder{w: w, buf: make([]byte, 0, encoderBufferSize)}
+	e.writeByte(binaryVersion)
+	set.writeBinary(&e)
+	e.flush()
+	// the checksum is not part of itself
+	e.writeWord(uint64(e.crc), 4)
+	e.flush()
+	return e.n, e.err
+}
+
+// ReadFrom implements io.ReaderFrom, replacing the members of this set with
+// those written by WriteTo. It reads exactly the bytes of one set, one at a
+// time if r is not an io.ByteReader, so wrap slow readers in a bufio.Reader.
+// It returns io.EOF if r has no more data, ErrTruncated if r ends within
+// the set, and ErrCorrupt if the data is not a valid set. The set is left
+// unchanged on error.
+func (set *IntSet) ReadFrom(r io.Reader) (int64, error) {
+	d := binaryDecoder{r: r}
+	if br, ok := r.(io.ByteReader); ok {
+		d.br = br
+	}
+	version, err := d.readByte()
+	if err != nil {
+		if d.n == 0 && errors.Is(err, ErrTruncated) {
+			return 0, io.EOF
+		}
+		return d.n, err
+	}
+	if version != 1 && version != binaryVersion {
+		return d.n, fmt.Errorf("%w: unknown version %d", ErrCorrupt, version)
+	}
+	decoded, err := d.readSet(true)
+	if err != nil {
+		return d.n, err
+	}
+	if version > 1 {
+		crc := d.crc
+		found, err := d.readWord(4)
+		if err != nil {
+			return d.n, err
+		}
+		if uint32(found) != crc {
+			return d.n, fmt.Errorf("%w: checksum %08x does not match %08x", ErrCorrupt, found, crc)
+		}
+	}
+	set.become(decoded)
+	return d.n, nil
+}
+
+const encoderBufferSize = 4096
+
+// binaryEncoder buffers encoded bytes, keeping a checksum and count of all
+// bytes written and the first error
+type binaryEncoder struct {
+	w   io.Writer
+	buf []byte
+	crc uint32
+	n   int64
+	err error
+}
+
+func (e *binaryEncoder) flush() {
+	if e.err == nil && len(e.buf) > 0 {
+		e.crc = crc32.Update(e.crc, crc32.IEEETable, e.buf)
+		n, err := e.w.Write(e.buf)
+		e.n += int64(n)
+		e.err = err
+	}
+	e.buf = e.buf[:0]
+}
+
+func (e *binaryEncoder) writeByte(b byte) {
+	if len(e.buf) >= encoderBufferSize {
+		e.flush()
+	}
+	e.buf = append(e.buf, b)
+}
+
+func (e *binaryEncoder) writeUvarint(x uint) {
+	if len(e.buf)+binary.MaxVarintLen64 > encoderBufferSize {
+		e.flush()
+	}
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(x))
+	e.buf = append(e.buf, buf[:n]...)
+}
+
+// writeWord writes the low size bytes of w, little endian
+func (e *binaryEncoder) writeWord(w uint64, size int) {
+	if len(e.buf)+size > encoderBufferSize {
+		e.flush()
+	}
+	for i := 0; i < size; i++ {
+		e.buf = append(e.buf, byte(w>>(8*i)))
+	}
+}
+
+func (set *IntSet) writeBinary(e *binaryEncoder) {
+	switch {
+	case set.IsEmpty():
+		e.writeByte(binaryEmpty)
+	case set.chunks != nil:
+		e.writeByte(binaryChunks)
+		e.writeUvarint(uint(len(set.chunks)))
+		for _, chunk := range set.chunks {
+			chunk.writeBinary(e)
+		}
+	case set.runs != nil:
+		e.writeByte(binaryRuns)
+		e.writeUvarint(uint(len(set.runs)))
+		var prev uint
+		for _, r := range set.runs {
+			e.writeUvarint(r.minValue - prev)
+			e.writeUvarint(r.maxValue - r.minValue)
+			prev = r.maxValue
+		}
+	case set.vs == nil:
+		e.writeByte(binaryInterval)
+		e.writeUvarint(set.minValue)
+		e.writeUvarint(set.maxValue - set.minValue)
+	default:
+		e.writeByte(binaryBitSet)
+		e.writeUvarint(set.vsStart)
+		e.writeUvarint(set.minValue - set.vsStart)
+		e.writeUvarint(set.maxValue - set.minValue)
+		start := (set.minValue - set.vsStart) >> 6
+		end := (set.maxValue - set.vsStart) >> 6
+		for i := start; i <= end; i++ {
+			e.writeWord(set.vs[i], 8)
+		}
+	}
+}
+
+// binaryDecoder reads encoded bytes, keeping a checksum and count of all
+// bytes read
+type binaryDecoder struct {
+	r   io.Reader
+	br  io.ByteReader // r, if it is one
+	buf [8]byte
+	crc uint32
+	n   int64
+}
+
+// readFull fills p, or returns ErrTruncated if the reader ends first
+func (d *binaryDecoder) readFull(p []byte) error {
+	n, err := io.ReadFull(d.r, p)
+	d.n += int64(n)
+	d.crc = crc32.Update(d.crc, crc32.IEEETable, p[:n])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w after %d bytes", ErrTruncated, d.n)
+	}
+	return err
+}
+
+func (d *binaryDecoder) readByte() (byte, error) {
+	if d.br == nil {
+		err := d.readFull(d.buf[:1])
+		return d.buf[0], err
+	}
+	b, err := d.br.ReadByte()
+	if err == io.EOF {
+		return 0, fmt.Errorf("%w after %d bytes", ErrTruncated, d.n)
+	}
+	if err != nil {
+		return 0, err
+	}
+	d.n++
+	d.buf[0] = b
+	d.crc = crc32.Update(d.crc, crc32.IEEETable, d.buf[:1])
+	return b, nil
+}
+
+func (d *binaryDecoder) readUvarint() (uint, error) {
+	var x uint64
+	var s uint
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		if b < 0x80 {
+			if (i == binary.MaxVarintLen64-1 && b > 1) || x|uint64(b)<<s > math.MaxUint {
+				break
+			}
+			return uint(x | uint64(b)<<s), nil
+		}
+		x |= uint64(b&0x7F) << s
+		s += 7
+	}
+	return 0, fmt.Errorf("%w: varint overflows at byte %d", ErrCorrupt, d.n)
+}
+
+// readWord reads size bytes as a little endian word
+func (d *binaryDecoder) readWord(size int) (uint64, error) {
+	if err := d.readFull(d.buf[:size]); err != nil {
+		return 0, err
+	}
+	var w uint64
+	for i := 0; i < size; i++ {
+		w |= uint64(d.buf[i]) << (8 * i)
+	}
+	return w, nil
+}
+
+// readCount reads a count of runs or chunks, of which there are at least two
+func (d *binaryDecoder) readCount(what string) (uint, error) {
+	n, err := d.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n < 2 {
+		return 0, fmt.Errorf("%w: %d %s at byte %d", ErrCorrupt, n, what, d.n)
+	}
+	return n, nil
+}
+
+// readBounds reads an offset from base and a length, returning the min and max values
+func (d *binaryDecoder) readBounds(base uint) (uint, uint, error) {
+	offset, err := d.readUvarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	span, err := d.readUvarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	if offset > math.MaxUint-base || span > math.MaxUint-base-offset {
+		return 0, 0, fmt.Errorf("%w: values overflow at byte %d", ErrCorrupt, d.n)
+	}
+	return base + offset, base + offset + span, nil
+}
+
+// readSet reads a set of any form, where chunks are allowed only at the top level
+func (d *binaryDecoder) readSet(allowChunks bool) (*IntSet, error) {
+	form, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch form {
+	case binaryEmpty:
+		return NewIntSet(), nil
+	case binaryInterval:
+		minV, maxV, err := d.readBounds(0)
+		if err != nil {
+			return nil, err
+		}
+		return NewIntSetFromInterval(minV, maxV), nil
+	case binaryRuns:
+		return d.readRuns()
+	case binaryBitSet:
+		return d.readBitSet()
+	case binaryChunks:
+		if allowChunks {
+			return d.readChunks()
+		}
+		return nil, fmt.Errorf("%w: chunk within a chunk at byte %d", ErrCorrupt, d.n)
+	}
+	return nil, fmt.Errorf("%w: unknown form %d at byte %d", ErrCorrupt, form, d.n)
+}
+
+// maxPrealloc limits the runs, chunks or words allocated before they are read,
+// so corrupt counts can't exhaust memory
+const maxPrealloc = 1024
+
+func (d *binaryDecoder) readRuns() (*IntSet, error) {
+	n, err := d.readCount("runs")
+	if err != nil {
+		return nil, err
+	}
+	set := IntSet{runs: make([]run, 0, min(n, maxPrealloc))}
+	var prev uint
+	for i := uint(0); i < n; i++ {
+		minV, maxV, err := d.readBounds(prev)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && (prev == math.MaxUint || minV <= prev+1) {
+			return nil, fmt.Errorf("%w: run %d touches the previous run at byte %d", ErrCorrupt, i, d.n)
+		}
+		set.runs = append(set.runs, run{minV, maxV})
+		set.cardinality += maxV - minV + 1
+		prev = maxV
+	}
+	set.minValue = set.runs[0].minValue
+	set.maxValue = set.runs[n-1].maxValue
+	return &set, nil
+}
+
+func (d *binaryDecoder) readBitSet() (*IntSet, error) {
+	vsStart, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if vsStart&0x3F != 0 {
+		return nil, fmt.Errorf("%w: bitset offset %d is not a multiple of 64", ErrCorrupt, vsStart)
+	}
+	minV, maxV, err := d.readBounds(vsStart)
+	if err != nil {
+		return nil, err
+	}
+	start := (minV - vsStart) >> 6
+	end := (maxV - vsStart) >> 6
+	words := make([]uint64, 0, min(end-start+1, maxPrealloc))
+	for i := start; i <= end; i++ {
+		w, err := d.readWord(8)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	if words[0]&^(AllBits<<(minV&0x3F)) != 0 || words[end-start]&^(AllBits>>(63-(maxV&0x3F))) != 0 {
+		return nil, fmt.Errorf("%w: bits set outside the bitset bounds at byte %d", ErrCorrupt, d.n)
+	}
+	if words[0]&(Bit<<(minV&0x3F)) == 0 || words[end-start]&(Bit<<(maxV&0x3F)) == 0 {
+		return nil, fmt.Errorf("%w: bitset bounds are not members at byte %d", ErrCorrupt, d.n)
+	}
+	if start > maxBitSetSpan>>6 {
+		// don't allocate a long run of leading zeros
+		vsStart += start << 6
+		start = 0
+	}
+	set := IntSet{minValue: minV, maxValue: maxV, vs: make([]uint64, start+uint(len(words))), vsStart: vsStart}
+	copy(set.vs[start:], words)
+	set.countMembers()
+	return &set, nil
+}
+
+func (d *binaryDecoder) readChunks() (*IntSet, error) {
+	n, err := d.readCount("chunks")
+	if err != nil {
+		return nil, err
+	}
+	set := IntSet{chunks: make([]*IntSet, 0, min(n, maxPrealloc)), cardinalityInvalidated: true}
+	for i := uint(0); i < n; i++ {
+		chunk, err := d.readSet(false)
+		if err != nil {
+			return nil, err
+		}
+		if chunk.IsEmpty() || chunkKey(chunk.minValue) != chunkKey(chunk.maxValue) {
+			return nil, fmt.Errorf("%w: chunk %d is empty or too large at byte %d", ErrCorrupt, i, d.n)
+		}
+		if i > 0 && chunkKey(chunk.minValue) <= chunkKey(set.chunks[i-1].minValue) {
+			return nil, fmt.Errorf("%w: chunk %d is out of order at byte %d", ErrCorrupt, i, d.n)
+		}
+		set.chunks = append(set.chunks, chunk)
+	}
+	set.minValue = set.chunks[0].minValue
+	set.maxValue = set.chunks[n-1].maxValue
+	return &set, nil
+}
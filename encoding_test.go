@@ -0,0 +1,148 @@
+package bitset
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func newChunkedSet() *IntSet {
+	set := NewIntSet()
+	for _, v := range newChunkedValues(5) {
+		set.Add(v)
+	}
+	return set
+}
+
+// sameForm checks two sets have the same representation and members
+func sameForm(a, b *IntSet) bool {
+	if (a.vs == nil) != (b.vs == nil) || len(a.runs) != len(b.runs) || len(a.chunks) != len(b.chunks) {
+		return false
+	}
+	if a.vs != nil && a.vsStart != b.vsStart {
+		return false
+	}
+	return a.Size() == b.Size() && a.CountIntersection(b) == a.Size()
+}
+
+func TestBinaryRoundTrip(test *testing.T) {
+	makers := newFormSets()
+	makers["wide"] = func() *IntSet { return NewIntSetFromInterval(1000, 1000000) }
+	makers["emptied"] = func() *IntSet { return newStepSet().Difference(NewIntSetFromInterval(0, 5000)) }
+	for name, makeSet := range makers {
+		set := makeSet()
+		data, err := set.MarshalBinary()
+		if err != nil {
+			test.Error("Bad marshal of", name, ":", err)
+			continue
+		}
+		decoded := NewIntSetFromInterval(1, 2)
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			test.Error("Bad unmarshal of", name, ":", err)
+			continue
+		}
+		if !sameForm(set, decoded) {
+			test.Error("Bad round trip of", name, ":", decoded.String(), "should be", set.String())
+		}
+	}
+	// an interval is two varints between the header and checksum
+	data, _ := NewIntSetFromInterval(5, 300).MarshalBinary()
+	if len(data) != 9 {
+		test.Error("Bad interval encoding length:", len(data), "should be 9")
+	}
+}
+
+func TestBinaryErrors(test *testing.T) {
+	for _, set := range []*IntSet{newRunsSet(), newStepSet(), newChunkedSet()} {
+		data, _ := set.MarshalBinary()
+		// truncation is an error rather than a panic
+		step := len(data)/500 + 1
+		for n := 0; n < len(data); n += step {
+			if err := NewIntSet().UnmarshalBinary(data[:n]); err == nil {
+				test.Error("Bad unmarshal of", n, "of", len(data), "bytes: should fail")
+				break
+			}
+		}
+		if err := NewIntSet().UnmarshalBinary(append(data, 0)); !errors.Is(err, ErrCorrupt) {
+			test.Error("Bad unmarshal with a trailing byte:", err, "should be corrupt")
+		}
+	}
+	bad := [][]byte{
+		{9, binaryEmpty},                           // unknown version
+		{binaryVersion, 42},                        // unknown form
+		{binaryVersion, binaryRuns, 2, 1, 1, 1, 0}, // touching runs
+		{binaryVersion, binaryBitSet, 3, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}, // misaligned offset
+		{binaryVersion, binaryBitSet, 0, 1, 0, 1, 0, 0, 0, 0, 0, 0, 0}, // bit below the min value
+		{1, binaryBitSet, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},             // min value not a member, in version 1 with no checksum
+		{1, binaryBitSet, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0},             // max value not a member
+		{binaryVersion, binaryChunks, 2, binaryChunks, 0, 0, 0},        // nested chunks
+	}
+	for i, data := range bad {
+		if err := NewIntSet().UnmarshalBinary(data); !errors.Is(err, ErrCorrupt) {
+			test.Error("Bad unmarshal of corrupt data", i, ":", err, "should be corrupt")
+		}
+	}
+}
+
+func TestStreamRoundTrip(test *testing.T) {
+	sets := []*IntSet{NewIntSetFromInterval(1000, 1000000), newRunsSet(), newStepSet(), newChunkedSet(), NewIntSet()}
+	var buf bytes.Buffer
+	var written int64
+	for _, set := range sets {
+		n, err := set.WriteTo(&buf)
+		if err != nil {
+			test.Error("Bad write:", err)
+		}
+		written += n
+	}
+	if written != int64(buf.Len()) {
+		test.Error("Bad written count:", written, "should be", buf.Len())
+	}
+	// hide the io.ByteReader so bytes are read one at a time
+	r := struct{ io.Reader }{&buf}
+	for i, set := range sets {
+		decoded := NewIntSet()
+		if _, err := decoded.ReadFrom(r); err != nil {
+			test.Error("Bad read of set", i, ":", err)
+			continue
+		}
+		if !sameForm(set, decoded) {
+			test.Error("Bad round trip of set", i, ":", decoded.String(), "should be", set.String())
+		}
+	}
+	if _, err := NewIntSet().ReadFrom(r); err != io.EOF {
+		test.Error("Bad read at the end of the stream:", err, "should be EOF")
+	}
+}
+
+func TestStreamErrors(test *testing.T) {
+	data, _ := newStepSet().MarshalBinary()
+	set := NewIntSetFromInterval(1, 2)
+	if _, err := set.ReadFrom(bytes.NewReader(data[:len(data)-3])); !errors.Is(err, ErrTruncated) {
+		test.Error("Bad read of a truncated stream:", err, "should be truncated")
+	}
+	corrupt := append([]byte(nil), data...)
+	corrupt[20] ^= 0x10
+	if _, err := set.ReadFrom(bytes.NewReader(corrupt)); !errors.Is(err, ErrCorrupt) {
+		test.Error("Bad read of a corrupt stream:", err, "should be corrupt")
+	}
+	if set.Size() != 2 {
+		test.Error("Bad set after failed reads:", set.String(), "should be unchanged")
+	}
+	// version 1 had no checksum
+	old := append([]byte{1}, data[1:len(data)-4]...)
+	if err := set.UnmarshalBinary(old); err != nil || set.Size() != 400 {
+		test.Error("Bad unmarshal of version 1:", err, set.Size())
+	}
+	// write errors are returned
+	if _, err := newStepSet().WriteTo(failingWriter{}); err != io.ErrClosedPipe {
+		test.Error("Bad write error:", err, "should be", io.ErrClosedPipe)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
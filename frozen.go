@@ -0,0 +1,122 @@
+package bitset
+
+import "iter"
+
+// FrozenIntSet is a set that can no longer change. Its count, bounds and rank
+// index are worked out when it is frozen, so none of its methods write
+// anything and it can be shared between goroutines without locking.
+type FrozenIntSet struct {
+	set *IntSet
+}
+
+// Freeze gets a frozen copy of the members of this set, which shares words
+// with it until this set changes
+func (set *IntSet) Freeze() *FrozenIntSet {
+	frozen := set.Clone()
+	frozen.settle(true)
+	return &FrozenIntSet{set: frozen}
+}
+
+// Thaw gets a copy of the members of this frozen set that can be changed
+func (f *FrozenIntSet) Thaw() *IntSet {
+	return f.set.Clone()
+}
+
+func (f *FrozenIntSet) Contains(x uint) bool {
+	return f.set.Contains(x)
+}
+
+func (f *FrozenIntSet) IsEmpty() bool {
+	return f.set.IsEmpty()
+}
+
+func (f *FrozenIntSet) Size() uint {
+	return f.set.Size()
+}
+
+func (f *FrozenIntSet) GetFirstValue() (bool, uint) {
+	return f.set.GetFirstValue()
+}
+
+func (f *FrozenIntSet) GetLastValue() (bool, uint) {
+	return f.set.GetLastValue()
+}
+
+func (f *FrozenIntSet) GetNextValue(x uint) (bool, uint) {
+	return f.set.GetNextValue(x)
+}
+
+func (f *FrozenIntSet) GetPrevValue(x uint) (bool, uint) {
+	return f.set.GetPrevValue(x)
+}
+
+func (f *FrozenIntSet) Rank(x uint) uint {
+	return f.set.Rank(x)
+}
+
+func (f *FrozenIntSet) Select(i uint) (uint, bool) {
+	return f.set.Select(i)
+}
+
+// CountIntersection counts the members shared with other, which must not be
+// changed during the call
+func (f *FrozenIntSet) CountIntersection(other *IntSet) uint {
+	return f.set.CountIntersection(other)
+}
+
+// CountIntersectionFrozen counts the members shared with another frozen set
+func (f *FrozenIntSet) CountIntersectionFrozen(other *FrozenIntSet) uint {
+	return f.set.CountIntersection(other.set)
+}
+
+func (f *FrozenIntSet) IsSubsetOf(other *IntSet) bool {
+	return f.set.IsSubsetOf(other)
+}
+
+func (f *FrozenIntSet) IsDisjointFrom(other *IntSet) bool {
+	return f.set.IsDisjointFrom(other)
+}
+
+func (f *FrozenIntSet) CountRange(lo, hi uint) uint {
+	return f.set.CountRange(lo, hi)
+}
+
+func (f *FrozenIntSet) ContainsRange(lo, hi uint) bool {
+	return f.set.ContainsRange(lo, hi)
+}
+
+func (f *FrozenIntSet) IntersectsRange(lo, hi uint) bool {
+	return f.set.IntersectsRange(lo, hi)
+}
+
+func (f *FrozenIntSet) AsUints() []uint {
+	return f.set.AsUints()
+}
+
+func (f *FrozenIntSet) AsInts() []int {
+	return f.set.AsInts()
+}
+
+func (f *FrozenIntSet) All() iter.Seq[uint] {
+	return f.set.All()
+}
+
+func (f *FrozenIntSet) Backward() iter.Seq[uint] {
+	return f.set.Backward()
+}
+
+func (f *FrozenIntSet) Runs() iter.Seq2[uint, uint] {
+	return f.set.Runs()
+}
+
+func (f *FrozenIntSet) Iterator() *Iterator {
+	return f.set.Iterator()
+}
+
+func (f *FrozenIntSet) MarshalBinary() ([]byte, error) {
+	return f.set.MarshalBinary()
+}
+
+func (f *FrozenIntSet) String() string {
+	return f.set.String()
+}
@@ -0,0 +1,99 @@
+package bitset
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFreeze(test *testing.T) {
+	makers := newFormSets()
+	for name, makeSet := range makers {
+		set := makeSet()
+		expected := set.Clone()
+		frozen := set.Freeze()
+		set.FlipRange(1000, 3000)
+		set.Add(1 << 40)
+
+		if frozen.Size() != expected.Size() || frozen.IsEmpty() != expected.IsEmpty() {
+			test.Error("Bad size of frozen", name, ":", frozen.Size(), "should be", expected.Size())
+		}
+		ok, first := frozen.GetFirstValue()
+		if okE, firstE := expected.GetFirstValue(); ok != okE || first != firstE {
+			test.Error("Bad first value of frozen", name, ":", first, "should be", firstE)
+		}
+		ok, last := frozen.GetLastValue()
+		if okE, lastE := expected.GetLastValue(); ok != okE || last != lastE {
+			test.Error("Bad last value of frozen", name, ":", last, "should be", lastE)
+		}
+		i := uint(0)
+		for v := range frozen.All() {
+			if frozen.Rank(v) != i+1 {
+				test.Error("Bad rank in frozen", name, "of", v, ":", frozen.Rank(v), "should be", i+1)
+				break
+			}
+			if s, ok := frozen.Select(i); !ok || s != v {
+				test.Error("Bad select in frozen", name, "of", i, ":", s, "should be", v)
+				break
+			}
+			i++
+		}
+		if i != expected.Size() || !sameMembers(frozen.Thaw(), expected) {
+			test.Error("Bad members of frozen", name)
+		}
+		other := newStepSet()
+		if frozen.CountIntersection(other) != expected.CountIntersection(other) {
+			test.Error("Bad intersection count of frozen", name, ":", frozen.CountIntersection(other), "should be", expected.CountIntersection(other))
+		}
+		if frozen.CountIntersectionFrozen(other.Freeze()) != expected.CountIntersection(other) {
+			test.Error("Bad intersection count of frozen", name, "with a frozen set")
+		}
+		thawed := frozen.Thaw()
+		thawed.AddRange(0, 5000)
+		if frozen.Size() != expected.Size() || frozen.Contains(4999) != expected.Contains(4999) {
+			test.Error("Bad frozen", name, "after changing a thawed copy")
+		}
+	}
+}
+
+func TestFreezeConcurrent(test *testing.T) {
+	set := newChunkedSet()
+	set.Union(newStepSet())
+	frozen := set.Freeze()
+	other := newRunsSet().Freeze()
+	size := frozen.Size()
+	_, last := frozen.GetLastValue()
+	var wg sync.WaitGroup
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func(r uint) {
+			defer wg.Done()
+			for i := uint(0); i < 200; i++ {
+				v, ok := frozen.Select((i*97 + r) % size)
+				if !ok || !frozen.Contains(v) || frozen.Rank(v) != (i*97+r)%size+1 {
+					test.Error("Bad select and rank of a shared frozen set:", v)
+					return
+				}
+				frozen.CountIntersectionFrozen(other)
+				if i%50 == 0 {
+					count := uint(0)
+					for range frozen.All() {
+						count++
+					}
+					if count != size {
+						test.Error("Bad iteration of a shared frozen set:", count, "should be", size)
+					}
+					thawed := frozen.Thaw()
+					thawed.Remove(last)
+				}
+			}
+		}(uint(r))
+	}
+	// the original can still change while the frozen copy is read
+	for i := uint(0); i < 1000; i++ {
+		set.Add(1001 + 5*i + 1)
+	}
+	wg.Wait()
+	if !frozen.Contains(last) || frozen.Size() != size {
+		test.Error("Bad frozen set after concurrent reads")
+	}
+}
@@ -0,0 +1,159 @@
+package bitset
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// All gets an iterator over the members of this set in increasing order. The
+// set should not be changed during iteration.
+func (set *IntSet) All() iter.Seq[uint] {
+	return func(yield func(uint) bool) {
+		set.forward(yield)
+	}
+}
+
+// Backward gets an iterator over the members of this set in decreasing order.
+// The set should not be changed during iteration.
+func (set *IntSet) Backward() iter.Seq[uint] {
+	return func(yield func(uint) bool) {
+		set.backward(yield)
+	}
+}
+
+// Runs gets an iterator over the maximal runs of consecutive members of this
+// set in increasing order, yielding the first and last value of each. The set
+// should not be changed during iteration.
+func (set *IntSet) Runs() iter.Seq2[uint, uint] {
+	return func(yield func(uint, uint) bool) {
+		// runs may continue from one chunk into the next, so hold each until it ends
+		var pending run
+		started := false
+		if !set.eachRun(func(r run) bool {
+			if started && r.minValue == pending.maxValue+1 {
+				pending.maxValue = r.maxValue
+				return true
+			}
+			if started && !yield(pending.minValue, pending.maxValue) {
+				return false
+			}
+			pending = r
+			started = true
+			return true
+		}) {
+			return
+		}
+		if started {
+			yield(pending.minValue, pending.maxValue)
+		}
+	}
+}
+
+// forward calls yield on each member in increasing order until it returns false,
+// returning false if it was stopped
+func (set *IntSet) forward(yield func(uint) bool) bool {
+	if set.IsEmpty() {
+		return true
+	}
+	if set.chunks != nil {
+		for _, chunk := range set.chunks {
+			if !chunk.forward(yield) {
+				return false
+			}
+		}
+		return true
+	}
+	if set.vs == nil {
+		for _, r := range set.asRuns() {
+			for v := r.minValue; ; v++ {
+				if !yield(v) {
+					return false
+				}
+				if v == r.maxValue {
+					break
+				}
+			}
+		}
+		return true
+	}
+	start := (set.minValue - set.vsStart) >> 6
+	end := (set.maxValue - set.vsStart) >> 6
+	for i := start; i <= end; i++ {
+		base := set.vsStart + (i << 6)
+		for w := set.vs[i]; w != 0; w &= w - 1 {
+			if !yield(base + uint(bits.TrailingZeros64(w))) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// backward calls yield on each member in decreasing order until it returns false,
+// returning false if it was stopped
+func (set *IntSet) backward(yield func(uint) bool) bool {
+	if set.IsEmpty() {
+		return true
+	}
+	if set.chunks != nil {
+		for i := len(set.chunks) - 1; i >= 0; i-- {
+			if !set.chunks[i].backward(yield) {
+				return false
+			}
+		}
+		return true
+	}
+	if set.vs == nil {
+		runs := set.asRuns()
+		for i := len(runs) - 1; i >= 0; i-- {
+			for v := runs[i].maxValue; ; v-- {
+				if !yield(v) {
+					return false
+				}
+				if v == runs[i].minValue {
+					break
+				}
+			}
+		}
+		return true
+	}
+	start := (set.minValue - set.vsStart) >> 6
+	end := (set.maxValue - set.vsStart) >> 6
+	for i := end + 1; i > start; i-- {
+		base := set.vsStart + ((i - 1) << 6)
+		for w := set.vs[i-1]; w != 0; {
+			b := 63 - uint(bits.LeadingZeros64(w))
+			if !yield(base + b) {
+				return false
+			}
+			w &^= 1 << b
+		}
+	}
+	return true
+}
+
+// eachRun calls fn on the runs of each chunk in increasing order until it
+// returns false, returning false if it was stopped. Runs are not merged
+// across chunks.
+func (set *IntSet) eachRun(fn func(run) bool) bool {
+	if set.IsEmpty() {
+		return true
+	}
+	if set.chunks != nil {
+		for _, chunk := range set.chunks {
+			if !chunk.eachRun(fn) {
+				return false
+			}
+		}
+		return true
+	}
+	if set.vs != nil {
+		return set.eachBitSetRun(set.minValue, set.maxValue, fn)
+	}
+	for _, r := range set.asRuns() {
+		if !fn(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package bitset
+
+import (
+	"testing"
+)
+
+func TestIterAll(test *testing.T) {
+	for _, set := range []*IntSet{NewIntSet(), NewIntSetFromInterval(0, 100), newRunsSet(), newStepSet(), newChunkedSet()} {
+		members := set.AsUints()
+		i := 0
+		for v := range set.All() {
+			if i >= len(members) || v != members[i] {
+				test.Error("Bad ID at index", i, ":", v)
+				break
+			}
+			i++
+		}
+		if i != len(members) {
+			test.Error("Bad count:", i, "should be", len(members))
+		}
+		i = len(members) - 1
+		for v := range set.Backward() {
+			if i < 0 || v != members[i] {
+				test.Error("Bad backward ID at index", i, ":", v)
+				break
+			}
+			i--
+		}
+		if i != -1 {
+			test.Error("Bad backward count:", len(members)-1-i, "should be", len(members))
+		}
+	}
+	// stopping early
+	count := 0
+	for v := range newChunkedSet().All() {
+		if count++; count == 10 || v == 0 {
+			break
+		}
+	}
+	if count != 10 {
+		test.Error("Bad count after break:", count, "should be 10")
+	}
+}
+
+func TestIterRuns(test *testing.T) {
+	// runs crossing words and chunks are joined
+	set := newStepSet().Union(NewIntSetFromInterval(3000, 3200))
+	set.Add(1<<40 - 1).Add(1 << 40).Add(1<<40 + 1)
+	for v := uint(1<<16 - 10); v < 1<<16+10; v++ {
+		set.Add(v)
+	}
+	var runs []run
+	for lo, hi := range set.Runs() {
+		runs = append(runs, run{lo, hi})
+	}
+	expected := set.Clone().Optimize().asRuns()
+	if len(runs) != len(expected) {
+		test.Error("Bad run count:", len(runs), "should be", len(expected))
+	}
+	for i := range runs {
+		if i < len(expected) && runs[i] != expected[i] {
+			test.Error("Bad run:", runs[i], "should be", expected[i])
+			break
+		}
+	}
+	if last := runs[len(runs)-1]; last.minValue != 1<<40-1 || last.maxValue != 1<<40+1 {
+		test.Error("Bad last run:", last, "should be across the chunks")
+	}
+	for lo, hi := range NewIntSet().Runs() {
+		test.Error("Bad run of the empty set:", lo, hi)
+	}
+}
@@ -0,0 +1,138 @@
+package bitset
+
+import (
+	"math/bits"
+)
+
+// Iterator reads the members of a set in increasing order, many at a time.
+// The set should not be changed while it is being read.
+type Iterator struct {
+	set   *IntSet
+	chunk int     // the index of leaf in the set's chunks
+	leaf  *IntSet // the set, or the chunk being read
+	runs  []run   // the runs of leaf, or nil if it is a bitset
+	run   int     // the run being read
+	next  uint    // the next value of the run
+	index uint    // the word of the bitset being read
+	end   uint    // the last word of the bitset
+	word  uint64  // the bits of the word not yet read
+	floor uint    // no members below this are left to read
+	done  bool
+}
+
+// Iterator gets an Iterator starting at the first member of this set
+func (set *IntSet) Iterator() *Iterator {
+	it := &Iterator{set: set}
+	it.seek(0)
+	return it
+}
+
+// NextMany fills buf with the next members of the set, returning how many
+// were read. Fewer than len(buf) are read only at the end of the set.
+func (it *Iterator) NextMany(buf []uint) int {
+	n := 0
+	for n < len(buf) && !it.done {
+		if it.runs == nil {
+			// decode whole words
+			base := it.leaf.vsStart + (it.index << 6)
+			for it.word != 0 && n < len(buf) {
+				buf[n] = base + uint(bits.TrailingZeros64(it.word))
+				it.word &= it.word - 1
+				n++
+			}
+			if it.word != 0 {
+				break
+			}
+			if it.index < it.end {
+				it.index++
+				it.word = it.leaf.vs[it.index]
+				continue
+			}
+		} else {
+			r := it.runs[it.run]
+			avail := uint(len(buf) - n)
+			if r.maxValue-it.next >= avail {
+				for i := range buf[n:] {
+					buf[n+i] = it.next + uint(i)
+				}
+				it.next += avail
+				n = len(buf)
+				break
+			}
+			for v := it.next; v <= r.maxValue; v++ {
+				buf[n] = v
+				n++
+				if v == r.maxValue {
+					break
+				}
+			}
+			if it.run++; it.run < len(it.runs) {
+				it.next = it.runs[it.run].minValue
+				continue
+			}
+		}
+		// this leaf has been read
+		if it.set.chunks != nil && it.chunk+1 < len(it.set.chunks) {
+			it.chunk++
+			it.start(it.set.chunks[it.chunk], 0)
+		} else {
+			it.done = true
+		}
+	}
+	if n > 0 {
+		if buf[n-1] == ^uint(0) {
+			it.done = true
+		}
+		it.floor = buf[n-1] + 1
+	}
+	return n
+}
+
+// AdvanceIfNeeded skips ahead so that the next member read is at least min.
+// It never goes backwards.
+func (it *Iterator) AdvanceIfNeeded(min uint) {
+	if !it.done && min > it.floor {
+		it.seek(min)
+	}
+}
+
+// seek moves to the first member at or after x
+func (it *Iterator) seek(x uint) {
+	it.floor = x
+	set := it.set
+	if set.IsEmpty() || x > set.maxValue {
+		it.done = true
+		return
+	}
+	if set.chunks == nil {
+		it.start(set, x)
+		return
+	}
+	it.chunk = findChunk(set.chunks, x)
+	if x > set.chunks[it.chunk].maxValue {
+		it.chunk++
+	}
+	it.start(set.chunks[it.chunk], x)
+}
+
+// start begins reading leaf from its first member at or after x, where x is
+// no more than its max value
+func (it *Iterator) start(leaf *IntSet, x uint) {
+	if x < leaf.minValue {
+		x = leaf.minValue
+	}
+	it.leaf = leaf
+	if leaf.vs != nil {
+		it.runs = nil
+		it.index = (x - leaf.vsStart) >> 6
+		it.end = (leaf.maxValue - leaf.vsStart) >> 6
+		it.word = leaf.vs[it.index] & (AllBits << (x & 0x3F))
+		return
+	}
+	it.runs = leaf.asRuns()
+	it.run = findRun(it.runs, x)
+	it.next = it.runs[it.run].minValue
+	if x > it.next {
+		it.next = x
+	}
+}
@@ -0,0 +1,96 @@
+package bitset
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestIteratorNextMany(test *testing.T) {
+	for _, set := range []*IntSet{NewIntSet(), NewIntSetFromInterval(0, 100), newRunsSet(), newStepSet(), newChunkedSet()} {
+		members := set.AsUints()
+		for _, size := range []int{1, 7, 64, 1000} {
+			it := set.Iterator()
+			buf := make([]uint, size)
+			var found []uint
+			for n := it.NextMany(buf); n > 0; n = it.NextMany(buf) {
+				found = append(found, buf[:n]...)
+			}
+			if len(found) != len(members) {
+				test.Error("Bad count with buffer", size, ":", len(found), "should be", len(members))
+				continue
+			}
+			for i, v := range found {
+				if v != members[i] {
+					test.Error("Bad ID with buffer", size, ":", v, "should be", members[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestIteratorAdvance(test *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, set := range []*IntSet{newRunsSet(), newStepSet(), newChunkedSet(), NewIntSetFromInterval(1<<40, 1<<40+50)} {
+		it := set.Iterator()
+		buf := make([]uint, 3)
+		v := set.minValue
+		for i := 0; i < 200; i++ {
+			// skip ahead by up to a few thousand values
+			min := v + uint(r.Intn(5000))
+			if i%5 == 0 {
+				min = set.minValue // never goes back
+			}
+			it.AdvanceIfNeeded(min)
+			if min < v {
+				min = v
+			}
+			n := it.NextMany(buf)
+			ok, expected := set.GetNextValue(min - 1)
+			if !ok {
+				if n != 0 {
+					test.Error("Bad values after the end:", buf[:n])
+				}
+				break
+			}
+			if n == 0 || buf[0] != expected {
+				test.Error("Bad value after advancing to", min, ":", buf[:n], "should start with", expected)
+				break
+			}
+			v = buf[n-1] + 1
+		}
+	}
+	it := newStepSet().Iterator()
+	it.AdvanceIfNeeded(1 << 50)
+	if n := it.NextMany(make([]uint, 10)); n != 0 {
+		test.Error("Bad count after advancing past the end:", n, "should be 0")
+	}
+}
+
+// a dense set of a million members
+func newDenseSet() *IntSet {
+	set := NewIntSetFromInterval(0, 1<<21)
+	for v := uint(0); v < 1<<21; v += 2 {
+		set.Remove(v)
+	}
+	return set
+}
+
+func BenchmarkAsUints(b *testing.B) {
+	set := newDenseSet()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set.AsUints()
+	}
+}
+
+func BenchmarkIteratorNextMany(b *testing.B) {
+	set := newDenseSet()
+	buf := make([]uint, 256)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := set.Iterator()
+		for n := it.NextMany(buf); n > 0; n = it.NextMany(buf) {
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package bitset
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// MarshalJSON implements json.Marshaler, writing the runs of the set as an
+// array of ranges, with single values on their own: [[1,100],[205],[300,310]]
+func (set *IntSet) MarshalJSON() ([]byte, error) {
+	buf := []byte{'['}
+	for i, r := range set.asRuns() {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, '[')
+		buf = strconv.AppendUint(buf, uint64(r.minValue), 10)
+		if r.maxValue != r.minValue {
+			buf = append(buf, ',')
+			buf = strconv.AppendUint(buf, uint64(r.maxValue), 10)
+		}
+		buf = append(buf, ']')
+	}
+	return append(buf, ']'), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the members of this set
+// with those of either the ranges written by MarshalJSON or a plain array of
+// values. The two may be mixed, and need not be in order. The set is left
+// unchanged on error, or if data is null.
+func (set *IntSet) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return fmt.Errorf("bitset: JSON set is not an array: %w", err)
+	}
+	runs := make([]run, 0, len(elements))
+	for i, element := range elements {
+		var bounds []json.Number
+		if element = bytes.TrimSpace(element); len(element) > 0 && element[0] == '[' {
+			if err := json.Unmarshal(element, &bounds); err != nil {
+				return fmt.Errorf("bitset: JSON range %d is not an array of values: %w", i, err)
+			}
+			if len(bounds) == 0 || len(bounds) > 2 {
+				return fmt.Errorf("bitset: JSON range %d has %d values, should be 1 or 2", i, len(bounds))
+			}
+		} else {
+			bounds = []json.Number{json.Number(element)}
+		}
+		var r run
+		var err error
+		if r.minValue, err = parseJSONValue(bounds[0]); err != nil {
+			return err
+		}
+		r.maxValue = r.minValue
+		if len(bounds) == 2 {
+			if r.maxValue, err = parseJSONValue(bounds[1]); err != nil {
+				return err
+			}
+			if r.maxValue < r.minValue {
+				return fmt.Errorf("bitset: JSON range %d ends before it starts", i)
+			}
+		}
+		runs = append(runs, r)
+	}
+	set.setRuns(sortRuns(runs))
+	return nil
+}
+
+func parseJSONValue(n json.Number) (uint, error) {
+	s := string(n)
+	if len(s) > 0 && s[0] == '-' {
+		return 0, fmt.Errorf("bitset: negative JSON value %s", s)
+	}
+	x, err := strconv.ParseUint(s, 10, strconv.IntSize)
+	if err != nil {
+		return 0, fmt.Errorf("bitset: JSON value %s is not an unsigned integer", s)
+	}
+	return uint(x), nil
+}
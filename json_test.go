@@ -0,0 +1,50 @@
+package bitset
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalJSON(test *testing.T) {
+	set := NewIntSetFromInterval(1, 100).Union(NewIntSetFromUInts([]uint{205})).Union(NewIntSetFromInterval(300, 310))
+	data, err := json.Marshal(set)
+	if err != nil || string(data) != "[[1,100],[205],[300,310]]" {
+		test.Error("Bad JSON:", string(data), err, "should be [[1,100],[205],[300,310]]")
+	}
+	data, _ = json.Marshal(NewIntSet())
+	if string(data) != "[]" {
+		test.Error("Bad empty JSON:", string(data), "should be []")
+	}
+	for _, set := range []*IntSet{newStepSet(), newRunsSet(), newChunkedSet()} {
+		data, _ := json.Marshal(set)
+		decoded := NewIntSet()
+		if err := json.Unmarshal(data, decoded); err != nil {
+			test.Error("Bad unmarshal:", err)
+		}
+		if decoded.Size() != set.Size() || decoded.CountIntersection(set) != set.Size() {
+			test.Error("Bad round trip:", decoded.String(), "should be", set.String())
+		}
+	}
+}
+
+func TestUnmarshalJSON(test *testing.T) {
+	set := NewIntSet()
+	if err := json.Unmarshal([]byte(" [7, [1,3], 2, [10], [5,6] ] "), set); err != nil {
+		test.Error("Bad unmarshal:", err)
+	}
+	if set.Size() != 7 || !set.Contains(7) || !set.Contains(10) || set.Contains(4) || set.Contains(8) {
+		test.Error("Bad members:", set.String(), "should be {1,2,3,5,6,7,10}")
+	}
+	for _, data := range []string{`{}`, `[-1]`, `[[1,-2]]`, `[1.5]`, `[1e3]`, `["a"]`, `[[]]`, `[[1,2,3]]`, `[[5,1]]`, `[true]`, `[18446744073709551616]`} {
+		if err := json.Unmarshal([]byte(data), set); err == nil {
+			test.Error("Bad unmarshal of", data, ": should be an error")
+		}
+	}
+	if set.Size() != 7 {
+		test.Error("Bad set after failed unmarshal:", set.String(), "should be unchanged")
+	}
+	var holder struct{ Set *IntSet }
+	if err := json.Unmarshal([]byte(`{"Set":[1,2,3]}`), &holder); err != nil || holder.Set.Size() != 3 {
+		test.Error("Bad unmarshal of a field:", err)
+	}
+}
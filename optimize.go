@@ -0,0 +1,95 @@
+package bitset
+
+import (
+	"math/bits"
+)
+
+// SetAutoOptimize sets whether this set calls Optimize after each Union,
+// Intersection, Difference and SymmetricDifference
+func (set *IntSet) SetAutoOptimize(on bool) *IntSet {
+	set.autoOptimize = on
+	return set
+}
+
+// Optimize switches a bitset back to an interval or runs wherever that takes
+// less space, releasing its words. Chunked sets are merged back into runs or
+// a single bitset if they have become small enough, or else have each chunk
+// optimized.
+func (set *IntSet) Optimize() *IntSet {
+	if set.chunks != nil {
+		if set.maxValue-set.minValue < maxBitSetSpan || set.countRuns() <= maxRuns {
+			return set.setRuns(set.chunkRuns())
+		}
+		for _, chunk := range set.chunks {
+			chunk.Optimize()
+		}
+		return set
+	}
+	if set.vs == nil {
+		return set
+	}
+	set.fitBounds()
+	if set.vs == nil {
+		return set // was empty
+	}
+	// same rule as fitRuns, two words per run
+	if n := set.countRuns(); n == 1 || n <= (set.maxValue-set.minValue)>>7 {
+		return set.setRuns(set.bitSetRuns(set.minValue, set.maxValue))
+	}
+	return set
+}
+
+func (set *IntSet) optimizeIfAuto() {
+	if set.autoOptimize {
+		set.Optimize()
+	}
+}
+
+// countRuns counts the runs of consecutive values in this set, where runs
+// crossing between chunks may be counted twice
+func (set *IntSet) countRuns() uint {
+	if set.IsEmpty() {
+		return 0
+	}
+	if set.chunks != nil {
+		var count uint
+		for _, chunk := range set.chunks {
+			count += chunk.countRuns()
+		}
+		return count
+	}
+	if set.runs != nil {
+		return uint(len(set.runs))
+	}
+	if set.vs == nil {
+		return 1
+	}
+	// count the first bit of each run, carrying the top bit of each word
+	start := (set.minValue - set.vsStart) >> 6
+	end := (set.maxValue - set.vsStart) >> 6
+	count := 0
+	var carry uint64
+	for i := start; i <= end; i++ {
+		w := set.vs[i]
+		count += bits.OnesCount64(w &^ (w<<1 | carry))
+		carry = w >> 63
+	}
+	return uint(count)
+}
+
+// become takes on the members and representation of other, which should not
+// be used afterwards
+func (set *IntSet) become(other *IntSet) *IntSet {
+	set.shared, set.borrowed = false, false // dropped rather than changed, so not copied
+	set.modified()
+	set.minValue = other.minValue
+	set.maxValue = other.maxValue
+	set.vs = other.vs
+	set.vsStart = other.vsStart
+	set.runs = other.runs
+	set.chunks = other.chunks
+	set.cardinalityInvalidated = other.cardinalityInvalidated
+	set.cardinality = other.cardinality
+	set.shared, set.borrowed = other.shared, other.borrowed
+	return set
+}
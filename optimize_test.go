@@ -0,0 +1,83 @@
+package bitset
+
+import (
+	"testing"
+)
+
+func TestOptimizeToInterval(test *testing.T) {
+	set := newStepSet()
+	for i := uint(1001); i < 3000; i++ {
+		set.Add(i)
+	}
+	if set.vs == nil {
+		test.Error("Bad representation: should be a bitset before Optimize")
+	}
+	set.Optimize()
+	if set.vs != nil || set.runs != nil {
+		test.Error("Bad representation: should be an interval after Optimize")
+	}
+	if set.Size() != 1999 || !set.Contains(1001) || !set.Contains(2999) || set.Contains(3000) {
+		test.Error("Bad members:", set.String(), "should be {1001..2999}")
+	}
+}
+
+func TestOptimizeToRuns(test *testing.T) {
+	set := newStepSet()
+	for i := uint(1001); i < 3000; i++ {
+		set.Add(i)
+	}
+	for i := uint(1500); i <= 1600; i++ {
+		set.Remove(i)
+	}
+	set.Optimize()
+	if set.vs != nil || len(set.runs) != 2 {
+		test.Error("Bad representation: should be two runs after Optimize")
+	}
+	if set.Size() != 1999-101 {
+		test.Error("Bad count:", set.Size(), "should be", 1999-101)
+	}
+	// a set with many short runs stays a bitset
+	set = newStepSet().Optimize()
+	if set.vs == nil {
+		test.Error("Bad representation: should still be a bitset")
+	}
+	// an emptied bitset becomes an empty interval
+	set.Difference(newStepSet()).Optimize()
+	if !set.IsEmpty() || set.vs != nil {
+		test.Error("Bad empty set after Optimize:", set.String())
+	}
+}
+
+func TestOptimizeChunks(test *testing.T) {
+	set := NewIntSet()
+	for _, v := range newChunkedValues(4) {
+		set.Add(v)
+	}
+	size := set.Size()
+	clone := set.Clone()
+	set.Optimize()
+	if set.chunks == nil {
+		test.Error("Bad representation: should still be chunked")
+	}
+	if set.Size() != size || set.CountIntersection(clone) != size {
+		test.Error("Bad members after Optimize:", set.Size(), "should be", size)
+	}
+	// chunks within a small range are merged together
+	set.Intersection(NewIntSetFromInterval(clone.minValue, clone.minValue+1<<16)).Optimize()
+	if set.chunks != nil {
+		test.Error("Bad representation: should no longer be chunked")
+	}
+}
+
+func TestAutoOptimize(test *testing.T) {
+	set := newStepSet().SetAutoOptimize(true)
+	set.Union(NewIntSetFromInterval(1000, 3000))
+	if set.vs != nil || set.runs != nil {
+		test.Error("Bad representation: should be an interval after Union")
+	}
+	set = newStepSet()
+	set.Union(NewIntSetFromInterval(1000, 3000))
+	if set.vs == nil {
+		test.Error("Bad representation: should be a bitset without auto optimize")
+	}
+}
@@ -0,0 +1,298 @@
+package bitset
+
+import (
+	"iter"
+	"math/bits"
+)
+
+const (
+	// persistentBits is the number of bits of a value used at each level of a
+	// persistent set, so each node has 32 children or words
+	persistentBits   = 5
+	persistentFanout = 1 << persistentBits
+	// persistentLeafBits is the number of bits of a value covered by a leaf
+	persistentLeafBits = 6 + persistentBits
+)
+
+// PersistentIntSet is a set that is never changed. With, Without and Union
+// get new versions instead, which share all but the changed path of a radix
+// tree with the old one, so old versions stay valid and cost little to keep.
+// The leaves of the tree hold 32 words of bits each, so dense ranges take a
+// bit per value. The zero value is an empty set.
+type PersistentIntSet struct {
+	root *persistentNode
+	// levels of inner nodes above the leaves
+	height uint
+}
+
+type persistentNode struct {
+	children []*persistentNode // nil at the leaves
+	words    []uint64          // nil above the leaves
+	count    uint              // members at or below this node
+}
+
+// NewPersistentIntSet gets an empty persistent set
+func NewPersistentIntSet() *PersistentIntSet {
+	return &PersistentIntSet{}
+}
+
+// NewPersistentIntSetFromIntSet gets a persistent set with the members of set
+func NewPersistentIntSetFromIntSet(set *IntSet) *PersistentIntSet {
+	p := &PersistentIntSet{}
+	ok, last := set.GetLastValue()
+	if !ok {
+		return p
+	}
+	for !persistentCovers(p.height, last) {
+		p.height++
+	}
+	// the tree is not yet shared, so can be filled in place
+	p.root = &persistentNode{}
+	for lo, hi := range set.Runs() {
+		for x := lo; ; x = (x | 0x3F) + 1 {
+			word := AllBits << (x & 0x3F)
+			if hi-(x&^0x3F) < 64 {
+				word &= AllBits >> (63 - (hi & 0x3F))
+			}
+			p.root.fill(p.height, x, word)
+			if hi-(x&^0x3F) < 64 {
+				break
+			}
+		}
+	}
+	return p
+}
+
+// ToIntSet gets an IntSet with the members of this set
+func (p *PersistentIntSet) ToIntSet() *IntSet {
+	values := make([]uint, 0, p.Size())
+	for v := range p.All() {
+		values = append(values, v)
+	}
+	return NewIntSetFromUInts(values)
+}
+
+// Size gets the number of members
+func (p *PersistentIntSet) Size() uint {
+	if p.root == nil {
+		return 0
+	}
+	return p.root.count
+}
+
+func (p *PersistentIntSet) IsEmpty() bool {
+	return p.root == nil
+}
+
+func (p *PersistentIntSet) Contains(x uint) bool {
+	if !persistentCovers(p.height, x) {
+		return false
+	}
+	n := p.root
+	for height := p.height; n != nil; height-- {
+		if height == 0 {
+			return n.words[(x>>6)&(persistentFanout-1)]&(Bit<<(x&0x3F)) != 0
+		}
+		n = n.children[persistentChild(height, x)]
+	}
+	return false
+}
+
+// With gets a version of this set with x added, or this set if x is already a member
+func (p *PersistentIntSet) With(x uint) *PersistentIntSet {
+	if p.Contains(x) {
+		return p
+	}
+	height := p.height
+	for !persistentCovers(height, x) {
+		height++
+	}
+	return &PersistentIntSet{root: p.raise(height).with(height, x), height: height}
+}
+
+// Without gets a version of this set with x removed, or this set if x is not a member
+func (p *PersistentIntSet) Without(x uint) *PersistentIntSet {
+	if !p.Contains(x) {
+		return p
+	}
+	return &PersistentIntSet{root: p.root.without(p.height, x), height: p.height}
+}
+
+// Union gets a version of this set with the members of other added. Any part
+// of the tree that the other set adds nothing to is shared with this set.
+func (p *PersistentIntSet) Union(other *PersistentIntSet) *PersistentIntSet {
+	height := max(p.height, other.height)
+	root := unionPersistent(p.raise(height), other.raise(height), height)
+	if root == p.root && height == p.height {
+		return p
+	}
+	return &PersistentIntSet{root: root, height: height}
+}
+
+// All gets an iterator over the members of this set in increasing order
+func (p *PersistentIntSet) All() iter.Seq[uint] {
+	return func(yield func(uint) bool) {
+		p.root.forward(p.height, 0, yield)
+	}
+}
+
+// persistentCovers gets whether a tree with the given height covers x
+func persistentCovers(height, x uint) bool {
+	shift := persistentLeafBits + persistentBits*height
+	return shift >= 64 || x>>shift == 0
+}
+
+// persistentChild gets which child of a node at the given height covers x
+func persistentChild(height, x uint) uint {
+	return (x >> (persistentLeafBits + persistentBits*(height-1))) & (persistentFanout - 1)
+}
+
+// raise gets the root of this set's tree grown to the given height, with the
+// old root as the first child of each new level
+func (p *PersistentIntSet) raise(height uint) *persistentNode {
+	root := p.root
+	if root == nil {
+		return nil
+	}
+	for h := p.height; h < height; h++ {
+		up := newPersistentNode(h + 1)
+		up.children[0] = root
+		up.count = root.count
+		root = up
+	}
+	return root
+}
+
+func newPersistentNode(height uint) *persistentNode {
+	if height == 0 {
+		return &persistentNode{words: make([]uint64, persistentFanout)}
+	}
+	return &persistentNode{children: make([]*persistentNode, persistentFanout)}
+}
+
+// copied gets a copy of this node that can be changed, or a new node if it is nil
+func (n *persistentNode) copied(height uint) *persistentNode {
+	if n == nil {
+		return newPersistentNode(height)
+	}
+	return &persistentNode{
+		children: append([]*persistentNode(nil), n.children...),
+		words:    append([]uint64(nil), n.words...),
+		count:    n.count,
+	}
+}
+
+// with gets a copy of the path to x with x added, which must not be a member
+func (n *persistentNode) with(height, x uint) *persistentNode {
+	c := n.copied(height)
+	c.count++
+	if height == 0 {
+		c.words[(x>>6)&(persistentFanout-1)] |= Bit << (x & 0x3F)
+		return c
+	}
+	i := persistentChild(height, x)
+	c.children[i] = c.children[i].with(height-1, x)
+	return c
+}
+
+// without gets a copy of the path to x with x removed, which must be a
+// member, dropping any node left empty
+func (n *persistentNode) without(height, x uint) *persistentNode {
+	if n.count == 1 {
+		return nil
+	}
+	c := n.copied(height)
+	c.count--
+	if height == 0 {
+		c.words[(x>>6)&(persistentFanout-1)] &^= Bit << (x & 0x3F)
+		return c
+	}
+	i := persistentChild(height, x)
+	c.children[i] = c.children[i].without(height-1, x)
+	return c
+}
+
+// fill sets the bits of word in the word holding x, changing this node and
+// those below it in place, so is only for trees that are not yet shared
+func (n *persistentNode) fill(height, x uint, word uint64) {
+	if height == 0 {
+		if n.words == nil {
+			n.words = make([]uint64, persistentFanout)
+		}
+		w := &n.words[(x>>6)&(persistentFanout-1)]
+		n.count += uint(bits.OnesCount64(word &^ *w))
+		*w |= word
+		return
+	}
+	if n.children == nil {
+		n.children = make([]*persistentNode, persistentFanout)
+	}
+	i := persistentChild(height, x)
+	child := n.children[i]
+	if child == nil {
+		child = &persistentNode{}
+		n.children[i] = child
+	}
+	n.count -= child.count
+	child.fill(height-1, x, word)
+	n.count += child.count
+}
+
+// unionPersistent gets the union of two nodes at the same height, returning
+// either one unchanged where it already holds the other
+func unionPersistent(a, b *persistentNode, height uint) *persistentNode {
+	if b == nil || a == b {
+		return a
+	}
+	if a == nil {
+		return b
+	}
+	c := newPersistentNode(height)
+	if height == 0 {
+		for i := range c.words {
+			c.words[i] = a.words[i] | b.words[i]
+			c.count += uint(bits.OnesCount64(c.words[i]))
+		}
+	} else {
+		for i := range c.children {
+			if child := unionPersistent(a.children[i], b.children[i], height-1); child != nil {
+				c.children[i] = child
+				c.count += child.count
+			}
+		}
+	}
+	// the union holds both, so has the same members as one with the same count
+	if c.count == a.count {
+		return a
+	}
+	if c.count == b.count {
+		return b
+	}
+	return c
+}
+
+// forward calls yield on each member below this node in increasing order,
+// where base is the first value the node covers, returning false if stopped
+func (n *persistentNode) forward(height, base uint, yield func(uint) bool) bool {
+	if n == nil {
+		return true
+	}
+	if height == 0 {
+		for i, w := range n.words {
+			for w != 0 {
+				if !yield(base + uint(i)<<6 + uint(bits.TrailingZeros64(w))) {
+					return false
+				}
+				w &= w - 1
+			}
+		}
+		return true
+	}
+	shift := persistentLeafBits + persistentBits*(height-1)
+	for i, child := range n.children {
+		if !child.forward(height-1, base+uint(i)<<shift, yield) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package bitset
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// samePersistent checks a persistent set has the same members as set
+func samePersistent(p *PersistentIntSet, set *IntSet) bool {
+	if p.Size() != set.Size() || p.IsEmpty() != set.IsEmpty() {
+		return false
+	}
+	count := uint(0)
+	for v := range p.All() {
+		if !set.Contains(v) {
+			return false
+		}
+		count++
+	}
+	return count == set.Size()
+}
+
+func TestPersistentConversion(test *testing.T) {
+	makers := newFormSets()
+	makers["top"] = func() *IntSet { return NewIntSetFromUInts([]uint{0, 63, 64, math.MaxUint - 64, math.MaxUint}) }
+	for name, makeSet := range makers {
+		set := makeSet()
+		p := NewPersistentIntSetFromIntSet(set)
+		if !samePersistent(p, set) {
+			test.Error("Bad persistent copy of", name, ":", p.Size(), "should be", set.Size())
+		}
+		if back := p.ToIntSet(); !sameMembers(back, set) {
+			test.Error("Bad conversion back from persistent", name, ":", back.Size(), "should be", set.Size())
+		}
+	}
+}
+
+func TestPersistentModel(test *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	value := func() uint {
+		switch r.Intn(20) {
+		case 0:
+			return math.MaxUint - uint(r.Intn(100))
+		case 1:
+			return 1<<40 + uint(r.Intn(1000))
+		}
+		return modelValue(r)
+	}
+	// every version is kept, with a copy of the members it should have
+	versions := []*PersistentIntSet{NewPersistentIntSet()}
+	models := []*IntSet{NewIntSet()}
+	for step := 0; step < 3000; step++ {
+		i := r.Intn(len(versions))
+		p, model := versions[i], models[i].Clone()
+		x := value()
+		name := ""
+		switch r.Intn(5) {
+		case 0, 1:
+			name = "with"
+			p = p.With(x)
+			model.Add(x)
+		case 2:
+			name = "without"
+			if v, ok := model.Select(uint(r.Intn(int(model.Size() + 1)))); ok && r.Intn(2) == 0 {
+				x = v
+			}
+			p = p.Without(x)
+			model.Remove(x)
+		default:
+			name = "union"
+			j := r.Intn(len(versions))
+			p = p.Union(versions[j])
+			model.Union(models[j])
+		}
+		if !samePersistent(p, model) {
+			test.Error("Bad members after", name, "in step", step, ":", p.Size(), "should be", model.Size())
+			break
+		}
+		if p.Contains(x) != model.Contains(x) {
+			test.Error("Bad membership of", x, "after", name, "in step", step)
+		}
+		versions = append(versions, p)
+		models = append(models, model)
+	}
+	for i, p := range versions {
+		if !samePersistent(p, models[i]) {
+			test.Error("Bad old version", i, ":", p.Size(), "should be", models[i].Size())
+		}
+	}
+}
+
+func TestPersistentSharing(test *testing.T) {
+	p := NewPersistentIntSetFromIntSet(newStepSet())
+	q := p.With(5000)
+	// only the path to the new member is copied
+	for i, child := range p.root.children {
+		if i != int(persistentChild(p.height, 5000)) && child != q.root.children[i] {
+			test.Error("Bad sharing after with: child", i, "was copied")
+		}
+	}
+	if p.Contains(5000) || !q.Contains(5000) {
+		test.Error("Bad versions after with")
+	}
+	if p.With(1001) != p || p.Without(1002) != p {
+		test.Error("Bad version for an unchanged set")
+	}
+	if p.Union(NewPersistentIntSet()) != p || q.Union(p) != q {
+		test.Error("Bad union that adds nothing")
+	}
+	u := p.Union(NewPersistentIntSet().With(1 << 50))
+	n := u.root
+	for h := u.height; h > p.height; h-- {
+		n = n.children[0]
+	}
+	if n != p.root || !u.Contains(1<<50) || u.Size() != p.Size()+1 {
+		test.Error("Bad sharing after a union that grew the tree")
+	}
+	var zero PersistentIntSet
+	if !zero.IsEmpty() || zero.Contains(0) || zero.With(7).Size() != 1 {
+		test.Error("Bad zero persistent set")
+	}
+}
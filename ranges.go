@@ -0,0 +1,275 @@
+package bitset
+
+// AddRange adds all values from lo to hi inclusive, extending or joining the
+// runs of an interval or run set, or setting whole words of a bitset
+func (set *IntSet) AddRange(lo, hi uint) *IntSet {
+	if lo > hi {
+		return set
+	}
+	set.modified()
+	if set.IsEmpty() {
+		return set.setRuns([]run{{lo, hi}})
+	}
+	if set.chunks != nil {
+		if set.fillsWide(lo, hi) {
+			return set.setRuns(unionRuns(set.chunkRuns(), []run{{lo, hi}}))
+		}
+		return set.rangeChunks(lo, hi, (*IntSet).AddRange, true)
+	}
+	if set.vs == nil {
+		return set.setRuns(unionRuns(set.asRuns(), []run{{lo, hi}}))
+	}
+	minV, maxV := rangeUnion(set.minValue, set.maxValue, lo, hi)
+	if maxV-minV >= maxBitSetSpan {
+		set.promoteToChunks()
+		return set.AddRange(lo, hi)
+	}
+	set.growBitSet(minV, maxV)
+	set.cardinality = set.Size() + (hi - lo + 1) - set.countBitRange(lo, hi)
+	set.setBitRange(lo, hi)
+	set.minValue = minV
+	set.maxValue = maxV
+	return set
+}
+
+// RemoveRange removes all values from lo to hi inclusive, shrinking or
+// splitting the runs of an interval or run set, or clearing whole words of a
+// bitset
+func (set *IntSet) RemoveRange(lo, hi uint) *IntSet {
+	lo, hi, ok := set.clampRange(lo, hi)
+	if !ok {
+		return set
+	}
+	set.modified()
+	if set.chunks != nil {
+		return set.rangeChunks(lo, hi, (*IntSet).RemoveRange, false)
+	}
+	if set.vs == nil {
+		return set.setRuns(differenceRuns(set.asRuns(), []run{{lo, hi}}))
+	}
+	set.cardinality = set.Size() - set.countBitRange(lo, hi)
+	set.clearBitRange(lo, hi)
+	set.fitBounds()
+	return set
+}
+
+// FlipRange adds each value from lo to hi inclusive that is not in this set,
+// and removes each one that is
+func (set *IntSet) FlipRange(lo, hi uint) *IntSet {
+	if lo > hi {
+		return set
+	}
+	set.modified()
+	if set.IsEmpty() {
+		return set.setRuns([]run{{lo, hi}})
+	}
+	if set.chunks != nil {
+		if set.fillsWide(lo, hi) {
+			return set.setRuns(symmetricDifferenceRuns(set.chunkRuns(), []run{{lo, hi}}))
+		}
+		return set.rangeChunks(lo, hi, (*IntSet).FlipRange, true)
+	}
+	if set.vs == nil {
+		return set.setRuns(symmetricDifferenceRuns(set.asRuns(), []run{{lo, hi}}))
+	}
+	minV, maxV := rangeUnion(set.minValue, set.maxValue, lo, hi)
+	if maxV-minV >= maxBitSetSpan {
+		set.promoteToChunks()
+		return set.FlipRange(lo, hi)
+	}
+	set.growBitSet(minV, maxV)
+	set.cardinality = set.Size() + (hi - lo + 1) - 2*set.countBitRange(lo, hi)
+	set.flipBitRange(lo, hi)
+	set.minValue = minV
+	set.maxValue = maxV
+	set.fitBounds()
+	return set
+}
+
+// rangeUnion gets the bounds of two ranges together
+func rangeUnion(minA, maxA, minB, maxB uint) (uint, uint) {
+	if minB < minA {
+		minA = minB
+	}
+	if maxB > maxA {
+		maxA = maxB
+	}
+	return minA, maxA
+}
+
+// fillsWide gets whether a range covers more chunk keys than a chunked set has
+// chunks, so filling each key would make many more chunks, and the range is
+// better applied to the runs of the set
+func (set *IntSet) fillsWide(lo, hi uint) bool {
+	return chunkKey(hi)-chunkKey(lo) >= uint(len(set.chunks))
+}
+
+// rangeChunks applies a range operation to each chunk overlapping lo to hi.
+// If fill, the operation is also applied to empty chunks within the range.
+func (set *IntSet) rangeChunks(lo, hi uint, op func(chunk *IntSet, lo, hi uint) *IntSet, fill bool) *IntSet {
+	i := findChunk(set.chunks, lo)
+	result := append([]*IntSet(nil), set.chunks[:i]...)
+	for key := chunkKey(lo); ; key++ {
+		var chunk *IntSet
+		if i < len(set.chunks) && chunkKey(set.chunks[i].minValue) == key {
+			chunk = set.chunks[i]
+			i++
+		} else if fill {
+			chunk = NewIntSet()
+		}
+		if chunk != nil {
+			// the part of the range within this chunk
+			a, b := rangeIntersection(key<<chunkBits, key<<chunkBits|chunkMask, lo, hi)
+			if op(chunk, a, b); !chunk.IsEmpty() {
+				result = append(result, chunk)
+			}
+		}
+		if key == chunkKey(hi) {
+			break
+		}
+		if !fill {
+			// skip ahead to the next chunk
+			if i == len(set.chunks) || chunkKey(set.chunks[i].minValue) > chunkKey(hi) {
+				break
+			}
+			key = chunkKey(set.chunks[i].minValue) - 1
+		}
+	}
+	return set.setChunks(append(result, set.chunks[i:]...))
+}
+
+// CountRange counts the members of this set from lo to hi inclusive
+func (set *IntSet) CountRange(lo, hi uint) uint {
+	lo, hi, ok := set.clampRange(lo, hi)
+	if !ok {
+		return 0
+	}
+	if set.chunks != nil {
+		var count uint
+		for i := findChunk(set.chunks, lo); i < len(set.chunks) && set.chunks[i].minValue <= hi; i++ {
+			count += set.chunks[i].CountRange(lo, hi)
+		}
+		return count
+	}
+	if set.runs != nil {
+		var count uint
+		for i := findRun(set.runs, lo); i < len(set.runs) && set.runs[i].minValue <= hi; i++ {
+			a, b := rangeIntersection(set.runs[i].minValue, set.runs[i].maxValue, lo, hi)
+			count += b - a + 1
+		}
+		return count
+	}
+	if set.vs == nil {
+		return hi - lo + 1
+	}
+	return set.countBitRange(lo, hi)
+}
+
+// ContainsRange checks whether every value from lo to hi inclusive is a member of this set
+func (set *IntSet) ContainsRange(lo, hi uint) bool {
+	if lo > hi {
+		return true
+	}
+	if set.IsEmpty() || lo < set.minValue || hi > set.maxValue {
+		return false
+	}
+	if set.chunks != nil {
+		// there must be a chunk for every key in the range
+		i := findChunk(set.chunks, lo)
+		for key := chunkKey(lo); ; key++ {
+			if i == len(set.chunks) || chunkKey(set.chunks[i].minValue) != key {
+				return false
+			}
+			a, b := rangeIntersection(key<<chunkBits, key<<chunkBits|chunkMask, lo, hi)
+			if !set.chunks[i].ContainsRange(a, b) {
+				return false
+			}
+			if key == chunkKey(hi) {
+				return true
+			}
+			i++
+		}
+	}
+	if set.runs != nil {
+		r := set.runs[findRun(set.runs, lo)]
+		return r.minValue <= lo && r.maxValue >= hi
+	}
+	if set.vs == nil {
+		return true
+	}
+	start := (lo - set.vsStart) >> 6
+	end := (hi - set.vsStart) >> 6
+	startMask := AllBits << (lo & 0x3F)
+	endMask := AllBits >> (63 - (hi & 0x3F))
+	if start == end {
+		return set.vs[start]&startMask&endMask == startMask&endMask
+	}
+	if set.vs[start]&startMask != startMask || set.vs[end]&endMask != endMask {
+		return false
+	}
+	for i := start + 1; i < end; i++ {
+		if set.vs[i] != AllBits {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsRange checks whether any value from lo to hi inclusive is a member of this set
+func (set *IntSet) IntersectsRange(lo, hi uint) bool {
+	lo, hi, ok := set.clampRange(lo, hi)
+	if !ok {
+		return false
+	}
+	if set.chunks != nil {
+		for i := findChunk(set.chunks, lo); i < len(set.chunks) && set.chunks[i].minValue <= hi; i++ {
+			if set.chunks[i].IntersectsRange(lo, hi) {
+				return true
+			}
+		}
+		return false
+	}
+	if set.runs != nil {
+		return set.runs[findRun(set.runs, lo)].minValue <= hi
+	}
+	if set.vs == nil {
+		return true
+	}
+	start := (lo - set.vsStart) >> 6
+	end := (hi - set.vsStart) >> 6
+	startMask := AllBits << (lo & 0x3F)
+	endMask := AllBits >> (63 - (hi & 0x3F))
+	if start == end {
+		return set.vs[start]&startMask&endMask != 0
+	}
+	if set.vs[start]&startMask != 0 || set.vs[end]&endMask != 0 {
+		return true
+	}
+	for i := start + 1; i < end; i++ {
+		if set.vs[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// clampRange gets the part of lo to hi within the bounds of this set, or false if there is none
+func (set *IntSet) clampRange(lo, hi uint) (uint, uint, bool) {
+	if set.IsEmpty() {
+		return 0, 0, false
+	}
+	lo, hi = rangeIntersection(set.minValue, set.maxValue, lo, hi)
+	return lo, hi, lo <= hi
+}
+
+// rangeIntersection gets the bounds of the overlap of two ranges, where the
+// min is above the max if they don't overlap
+func rangeIntersection(minA, maxA, minB, maxB uint) (uint, uint) {
+	if minB > minA {
+		minA = minB
+	}
+	if maxB < maxA {
+		maxA = maxB
+	}
+	return minA, maxA
+}
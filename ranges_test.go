@@ -0,0 +1,196 @@
+package bitset
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRanges(test *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	makers := newFormSets()
+	ops := []struct {
+		name string
+		op   func(set *IntSet, lo, hi uint) *IntSet
+		keep func(in, inRange bool) bool
+	}{
+		{"add", (*IntSet).AddRange, func(in, inRange bool) bool { return in || inRange }},
+		{"remove", (*IntSet).RemoveRange, func(in, inRange bool) bool { return in && !inRange }},
+		{"flip", (*IntSet).FlipRange, func(in, inRange bool) bool { return in != inRange }},
+	}
+	for name, makeSet := range makers {
+		for _, o := range ops {
+			set := makeSet()
+			for i := 0; i < 20; i++ {
+				before := set.Clone()
+				// ranges around the existing members
+				base := uint(1000)
+				if ok, first := before.GetFirstValue(); ok {
+					base = first
+				}
+				lo := base + uint(r.Intn(3000))
+				if lo >= 500 {
+					lo -= 500
+				}
+				hi := lo + uint(r.Intn(1<<uint(r.Intn(18))))
+				o.op(set, lo, hi)
+
+				candidates := []uint{lo - 1, lo, hi, hi + 1, lo + (hi-lo)/2}
+				var members []uint
+				for v := range before.All() {
+					if v+1 >= lo && v <= hi+1 {
+						candidates = append(candidates, v)
+					}
+					if o.keep(true, v >= lo && v <= hi) {
+						members = append(members, v)
+					}
+				}
+				size := uint(len(members))
+				if o.name != "remove" {
+					size += hi - lo + 1 - before.Rank(hi) + before.Rank(lo-1)
+				}
+				for _, v := range candidates {
+					if expected := o.keep(before.Contains(v), v >= lo && v <= hi); set.Contains(v) != expected {
+						test.Error("Bad", o.name, "of", lo, hi, "on", name, ":", v, "should be", expected)
+						break
+					}
+				}
+				if set.Size() != size {
+					test.Error("Bad", o.name, "count of", lo, hi, "on", name, ":", set.Size(), "should be", size)
+				}
+				var first, last uint
+				count := 0
+				for v := range set.All() {
+					if count == 0 {
+						first = v
+					}
+					last = v
+					count++
+				}
+				if count > 0 && (first != set.minValue || last != set.maxValue) {
+					test.Error("Bad", o.name, "bounds on", name, ":", set.minValue, set.maxValue, "should be", first, last)
+				}
+				if count == 0 && !set.IsEmpty() {
+					test.Error("Bad", o.name, "on", name, ": should be empty")
+				}
+			}
+		}
+	}
+}
+
+func TestRangesForms(test *testing.T) {
+	set := NewIntSetFromInterval(10, 2000).AddRange(2001, 3000)
+	if set.vs != nil || set.runs != nil || set.Size() != 2991 {
+		test.Error("Bad extended interval:", set.String())
+	}
+	set.RemoveRange(15, 16)
+	if len(set.runs) != 2 || set.Size() != 2989 {
+		test.Error("Bad split interval:", set.String())
+	}
+	set.FlipRange(15, 16)
+	if set.runs != nil || set.Size() != 2991 {
+		test.Error("Bad flipped interval:", set.String())
+	}
+	set = newStepSet().AddRange(1<<30, 1<<30+9)
+	if set.chunks == nil || set.Size() != 410 || !set.Contains(1<<30+9) {
+		test.Error("Bad range added far from a bitset:", set.Size(), "should be 410 in chunks")
+	}
+	set = newChunkedSet()
+	size := set.Size()
+	set.AddRange(1<<20, 1<<20+199999)
+	if set.Size() != size+200000 || !set.Contains(1<<20+70000) {
+		test.Error("Bad range added to chunks:", set.Size(), "should be", size+200000)
+	}
+	set.RemoveRange(0, 1<<62)
+	if !set.IsEmpty() {
+		test.Error("Bad removal of all chunks:", set.String())
+	}
+}
+
+func TestRangeQueries(test *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for _, set := range []*IntSet{NewIntSet(), NewIntSetFromInterval(1500, 2500), newRunsSet(), newStepSet(), newChunkedSet()} {
+		members := set.AsUints()
+		for i := 0; i < 300; i++ {
+			var lo uint
+			if len(members) > 0 {
+				lo = members[r.Intn(len(members))] - uint(r.Intn(3))
+			}
+			hi := lo + uint(r.Intn(1<<uint(r.Intn(12))))
+			var count uint
+			for _, v := range members {
+				if v >= lo && v <= hi {
+					count++
+				}
+			}
+			if found := set.CountRange(lo, hi); found != count {
+				test.Error("Bad count of", lo, hi, ":", found, "should be", count)
+			}
+			if found := set.ContainsRange(lo, hi); found != (count == hi-lo+1) {
+				test.Error("Bad contains of", lo, hi, ":", found, "should be", count == hi-lo+1)
+			}
+			if found := set.IntersectsRange(lo, hi); found != (count > 0) {
+				test.Error("Bad intersects of", lo, hi, ":", found, "should be", count > 0)
+			}
+		}
+	}
+	// ranges over many chunks
+	set := newChunkedSet().AddRange(1<<20, 1<<22)
+	if !set.ContainsRange(1<<20+5, 1<<22) || set.ContainsRange(1<<20-1, 1<<22) || set.CountRange(1<<20, 1<<22) != 1<<22-1<<20+1 {
+		test.Error("Bad range queries across chunks")
+	}
+	if !set.ContainsRange(5, 4) || set.IntersectsRange(5, 4) || set.CountRange(5, 4) != 0 {
+		test.Error("Bad range queries of an empty range")
+	}
+}
+
+func TestRangesWide(test *testing.T) {
+	original := newChunkedSet()
+	size := original.Size()
+	_, last := original.GetLastValue()
+	wide := uint(1 << 44)
+	interval := NewIntSetFromInterval(0, wide)
+	results := map[string]*IntSet{
+		"add range":        newChunkedSet().AddRange(0, wide),
+		"union":            newChunkedSet().Union(interval),
+		"interval union":   NewIntSetFromInterval(0, wide).Union(original),
+		"or":               Or(original, interval),
+		"union all":        UnionAll(original, interval, newStepSet()),
+		"at least one":     AtLeast(1, original, interval),
+		"flip range":       newChunkedSet().FlipRange(0, wide),
+		"xor":              Xor(original, interval),
+		"and not":          AndNot(interval, original),
+		"difference":       NewIntSetFromInterval(0, wide).Difference(original),
+		"intersection":     NewIntSetFromInterval(0, wide).Intersection(original),
+		"and":              And(interval, original),
+		"at least two":     AtLeast(2, original, interval, NewIntSetFromInterval(wide+10, wide+20)),
+		"symmetric":        newChunkedSet().SymmetricDifference(interval),
+		"intersect all":    IntersectAll(interval, original),
+		"remove and union": NewIntSetFromInterval(0, wide).RemoveRange(1<<30, 1<<31).Union(original),
+	}
+	for name, set := range results {
+		// no more chunks than the chunked set started with
+		if len(set.chunks) > len(original.chunks) {
+			test.Error("Bad form of", name, "over a wide range:", len(set.chunks), "chunks")
+		}
+		var expected uint
+		switch name {
+		case "add range", "union", "interval union", "or", "union all", "at least one":
+			expected = wide + 1
+		case "remove and union":
+			expected = wide + 1 - (1<<31 - 1<<30 + 1) + original.CountRange(1<<30, 1<<31)
+		case "flip range", "xor", "and not", "difference", "symmetric":
+			expected = wide + 1 - size
+		default:
+			expected = size
+		}
+		if set.Size() != expected {
+			test.Error("Bad size of", name, "over a wide range:", set.Size(), "should be", expected)
+		}
+		if in := set.Contains(last); in != (expected != wide+1-size) {
+			test.Error("Bad member", last, "of", name, "over a wide range:", in)
+		}
+		if !set.Contains(wide) && expected != size {
+			test.Error("Bad last member of", name, "over a wide range")
+		}
+	}
+}
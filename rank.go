@@ -0,0 +1,130 @@
+package bitset
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// rankBlockWords is the number of bitset words covered by each count in a rank index
+const rankBlockWords = 16
+
+// modified drops anything cached about the members of this set and copies
+// any words or runs shared with a clone, and is called by every method that
+// changes them
+func (set *IntSet) modified() {
+	set.rankIndex = nil
+	if set.shared {
+		set.unshare()
+	}
+}
+
+// Rank counts the members of this set that are no more than x. The first call
+// on a set of runs, chunks or a bitset builds an index of cumulative counts,
+// which later calls use until the set is changed.
+func (set *IntSet) Rank(x uint) uint {
+	if set.IsEmpty() || x < set.minValue {
+		return 0
+	}
+	if x > set.maxValue {
+		x = set.maxValue
+	}
+	if set.vs == nil && set.runs == nil && set.chunks == nil {
+		return x - set.minValue + 1
+	}
+	index := set.buildRankIndex()
+	if set.chunks != nil {
+		i := findChunk(set.chunks, x)
+		return index[i] + set.chunks[i].Rank(x)
+	}
+	if set.runs != nil {
+		i := findRun(set.runs, x)
+		count := index[i]
+		if r := set.runs[i]; x >= r.minValue {
+			count += x - r.minValue + 1
+		}
+		return count
+	}
+	w := (x - set.vsStart) >> 6
+	count := index[w/rankBlockWords]
+	for i := w - w%rankBlockWords; i < w; i++ {
+		count += uint(bits.OnesCount64(set.vs[i]))
+	}
+	return count + uint(bits.OnesCount64(set.vs[w]&(AllBits>>(63-(x&0x3F)))))
+}
+
+// Select gets the member of this set with i members below it, so Select(0)
+// is the first member. It returns false if the set has no more than i members.
+func (set *IntSet) Select(i uint) (uint, bool) {
+	if set.IsEmpty() {
+		return 0, false
+	}
+	if set.vs == nil && set.runs == nil && set.chunks == nil {
+		if i > set.maxValue-set.minValue {
+			return 0, false
+		}
+		return set.minValue + i, true
+	}
+	index := set.buildRankIndex()
+	if i >= index[len(index)-1] {
+		return 0, false
+	}
+	// the last count that is no more than i
+	j := sort.Search(len(index), func(k int) bool { return index[k] > i }) - 1
+	i -= index[j]
+	if set.chunks != nil {
+		return set.chunks[j].Select(i)
+	}
+	if set.runs != nil {
+		return set.runs[j].minValue + i, true
+	}
+	for w := uint(j) * rankBlockWords; ; w++ {
+		n := uint(bits.OnesCount64(set.vs[w]))
+		if i < n {
+			return set.vsStart + (w << 6) + selectInWord(set.vs[w], i), true
+		}
+		i -= n
+	}
+}
+
+// selectInWord gets the position of the bit in w with i set bits below it
+func selectInWord(w uint64, i uint) uint {
+	for ; i > 0; i-- {
+		w &= w - 1
+	}
+	return uint(bits.TrailingZeros64(w))
+}
+
+// buildRankIndex gets the count of members before each chunk, run or block of
+// bitset words, followed by the total count
+func (set *IntSet) buildRankIndex() []uint {
+	if set.rankIndex != nil {
+		return set.rankIndex
+	}
+	var index []uint
+	var count uint
+	switch {
+	case set.chunks != nil:
+		index = make([]uint, len(set.chunks)+1)
+		for i, chunk := range set.chunks {
+			index[i] = count
+			count += chunk.Size()
+		}
+	case set.runs != nil:
+		index = make([]uint, len(set.runs)+1)
+		for i, r := range set.runs {
+			index[i] = count
+			count += r.size()
+		}
+	default:
+		index = make([]uint, (len(set.vs)+rankBlockWords-1)/rankBlockWords+1)
+		for i, w := range set.vs {
+			if i%rankBlockWords == 0 {
+				index[i/rankBlockWords] = count
+			}
+			count += uint(bits.OnesCount64(w))
+		}
+	}
+	index[len(index)-1] = count
+	set.rankIndex = index
+	return index
+}
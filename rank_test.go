@@ -0,0 +1,53 @@
+package bitset
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestRankSelect(test *testing.T) {
+	for _, set := range []*IntSet{NewIntSet(), NewIntSetFromInterval(10, 100), newRunsSet(), newStepSet(), newChunkedSet()} {
+		members := set.AsUints()
+		candidates := []uint{0, 1 << 62}
+		for i, v := range members {
+			if i%7 == 0 {
+				candidates = append(candidates, v-1, v, v+1)
+			}
+		}
+		for _, x := range candidates {
+			expected := uint(sort.Search(len(members), func(i int) bool { return members[i] > x }))
+			if rank := set.Rank(x); rank != expected {
+				test.Error("Bad rank of", x, ":", rank, "should be", expected)
+				break
+			}
+		}
+		for i, v := range members {
+			if found, ok := set.Select(uint(i)); !ok || found != v {
+				test.Error("Bad select of", i, ":", found, ok, "should be", v)
+				break
+			}
+		}
+		if _, ok := set.Select(uint(len(members))); ok {
+			test.Error("Bad select past the end: should be false")
+		}
+	}
+}
+
+func TestRankModified(test *testing.T) {
+	set := newStepSet()
+	if rank := set.Rank(2000); rank != 200 {
+		test.Error("Bad rank:", rank, "should be 200")
+	}
+	set.Add(1002).Remove(1996)
+	if rank := set.Rank(2000); rank != 200 {
+		test.Error("Bad rank after changes:", rank, "should be 200")
+	}
+	set.Add(1003)
+	if v, _ := set.Select(2); v != 1003 {
+		test.Error("Bad select after add:", v, "should be 1003")
+	}
+	set.Union(NewIntSetFromInterval(0, 9))
+	if rank := set.Rank(1001); rank != 11 {
+		test.Error("Bad rank after union:", rank, "should be 11")
+	}
+}
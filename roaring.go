@@ -0,0 +1,303 @@
+package bitset
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// cookies starting the Roaring portable format, with and without run containers
+const (
+	roaringCookie       = 12347
+	roaringCookieNoRuns = 12346
+
+	// containers of more values than this are bitmaps, unless they are runs
+	roaringMaxArray = 4096
+	// the offset header is left out if there are run containers and fewer than this many containers
+	roaringNoOffsetThreshold = 4
+)
+
+// ErrRoaringRange is returned when exporting a set with values that don't fit in 32 bits
+var ErrRoaringRange = errors.New("bitset: values too large for roaring")
+
+// roaringContainer is one chunk of a set, as it is written in the Roaring format
+type roaringContainer struct {
+	key         uint
+	cardinality uint
+	runs        []run   // for run containers, relative to the key
+	chunk       *IntSet // for array and bitmap containers
+}
+
+func (c *roaringContainer) size() int64 {
+	switch {
+	case c.runs != nil:
+		return 2 + 4*int64(len(c.runs))
+	case c.cardinality <= roaringMaxArray:
+		return 2 * int64(c.cardinality)
+	}
+	return 1 << chunkBits / 8
+}
+
+// ExportRoaring writes this set in the Roaring portable serialization format,
+// as read by the Java, C and Go Roaring libraries. Each chunk becomes one
+// container: interval and run chunks become run containers, and bitset chunks
+// become array or bitmap containers by their size. The format holds 32 bit
+// values, so ErrRoaringRange is returned for sets with larger values.
+func (set *IntSet) ExportRoaring(w io.Writer) error {
+	if !set.IsEmpty() && set.maxValue > math.MaxUint32 {
+		return fmt.Errorf("%w: %d", ErrRoaringRange, set.maxValue)
+	}
+	chunks := set.chunks
+	if chunks == nil {
+		chunks = set.splitChunks()
+	}
+	containers := make([]roaringContainer, len(chunks))
+	hasRuns := false
+	for i, chunk := range chunks {
+		c := &containers[i]
+		c.key = chunkKey(chunk.minValue)
+		c.cardinality = chunk.Size()
+		if chunk.vs == nil {
+			base := c.key << chunkBits
+			for _, r := range chunk.asRuns() {
+				c.runs = append(c.runs, run{r.minValue - base, r.maxValue - base})
+			}
+			hasRuns = true
+		} else {
+			c.chunk = chunk
+		}
+	}
+
+	e := binaryEncoder{w: w, buf: make([]byte, 0, encoderBufferSize)}
+	n := int64(len(containers))
+	offset := 4 + 4*n
+	if hasRuns {
+		e.writeWord(uint64(roaringCookie|(n-1)<<16), 4)
+		for i := 0; i < len(containers); i += 8 {
+			var b byte
+			for j := 0; j < 8 && i+j < len(containers); j++ {
+				if containers[i+j].runs != nil {
+					b |= 1 << j
+				}
+			}
+			e.writeByte(b)
+		}
+		offset += (n + 7) / 8
+	} else {
+		e.writeWord(roaringCookieNoRuns, 4)
+		e.writeWord(uint64(n), 4)
+		offset += 4
+	}
+	for _, c := range containers {
+		e.writeWord(uint64(c.key), 2)
+		e.writeWord(uint64(c.cardinality-1), 2)
+	}
+	if !hasRuns || n >= roaringNoOffsetThreshold {
+		offset += 4 * n
+		for _, c := range containers {
+			e.writeWord(uint64(offset), 4)
+			offset += c.size()
+		}
+	}
+	for _, c := range containers {
+		c.write(&e)
+	}
+	e.flush()
+	return e.err
+}
+
+func (c *roaringContainer) write(e *binaryEncoder) {
+	if c.runs != nil {
+		e.writeWord(uint64(len(c.runs)), 2)
+		for _, r := range c.runs {
+			e.writeWord(uint64(r.minValue), 2)
+			e.writeWord(uint64(r.maxValue-r.minValue), 2)
+		}
+		return
+	}
+	chunk := c.chunk
+	base := c.key << chunkBits
+	if c.cardinality <= roaringMaxArray {
+		for ok, v := chunk.GetFirstValue(); ok; ok, v = chunk.GetNextValue(v) {
+			e.writeWord(uint64(v-base), 2)
+		}
+		return
+	}
+	// the words of a chunk may start below the key, but are zero outside the bounds
+	var words [1 << chunkBits / 64]uint64
+	start := (chunk.minValue - chunk.vsStart) >> 6
+	end := (chunk.maxValue - chunk.vsStart) >> 6
+	for i := start; i <= end; i++ {
+		words[(chunk.vsStart+i<<6-base)>>6] = chunk.vs[i]
+	}
+	for _, w := range words {
+		e.writeWord(w, 8)
+	}
+}
+
+// ImportRoaring reads a set written in the Roaring portable serialization
+// format, reading exactly the bytes of one bitmap. Each container becomes one
+// chunk: run containers become intervals or runs, and array and bitmap
+// containers become bitsets. Errors wrap ErrTruncated or ErrCorrupt as for
+// ReadFrom.
+func ImportRoaring(r io.Reader) (*IntSet, error) {
+	d := binaryDecoder{r: r}
+	if br, ok := r.(io.ByteReader); ok {
+		d.br = br
+	}
+	cookie, err := d.readWord(4)
+	if err != nil {
+		return nil, err
+	}
+	var n uint
+	var runFlags []byte
+	switch {
+	case cookie&0xFFFF == roaringCookie:
+		n = uint(cookie>>16) + 1
+		runFlags = make([]byte, (n+7)/8)
+		if err := d.readFull(runFlags); err != nil {
+			return nil, err
+		}
+	case cookie == roaringCookieNoRuns:
+		count, err := d.readWord(4)
+		if err != nil {
+			return nil, err
+		}
+		if count > 1<<16 {
+			return nil, fmt.Errorf("%w: %d roaring containers", ErrCorrupt, count)
+		}
+		n = uint(count)
+	default:
+		return nil, fmt.Errorf("%w: unknown roaring cookie %08x", ErrCorrupt, cookie)
+	}
+	containers := make([]roaringContainer, n)
+	for i := range containers {
+		c := &containers[i]
+		key, err := d.readWord(2)
+		if err != nil {
+			return nil, err
+		}
+		cardinality, err := d.readWord(2)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && uint(key) <= containers[i-1].key {
+			return nil, fmt.Errorf("%w: roaring container %d is out of order at byte %d", ErrCorrupt, i, d.n)
+		}
+		c.key = uint(key)
+		c.cardinality = uint(cardinality) + 1
+	}
+	var offsets []uint64
+	if runFlags == nil || n >= roaringNoOffsetThreshold {
+		offsets = make([]uint64, n)
+		for i := range offsets {
+			if offsets[i], err = d.readWord(4); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	chunks := make([]*IntSet, 0, n)
+	for i := range containers {
+		c := &containers[i]
+		if offsets != nil && offsets[i] != uint64(d.n) {
+			return nil, fmt.Errorf("%w: roaring container %d at byte %d, not %d", ErrCorrupt, i, d.n, offsets[i])
+		}
+		var chunk *IntSet
+		switch {
+		case runFlags != nil && runFlags[i/8]&(1<<(i%8)) != 0:
+			chunk, err = d.readRoaringRuns(c)
+		case c.cardinality <= roaringMaxArray:
+			chunk, err = d.readRoaringArray(c)
+		default:
+			chunk, err = d.readRoaringBitmap(c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return NewIntSet().setChunks(chunks), nil
+}
+
+func (d *binaryDecoder) readRoaringRuns(c *roaringContainer) (*IntSet, error) {
+	n, err := d.readWord(2)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w: roaring run container with no runs at byte %d", ErrCorrupt, d.n)
+	}
+	base := c.key << chunkBits
+	runs := make([]run, 0, n)
+	var count uint
+	for i := uint64(0); i < n; i++ {
+		start, err := d.readWord(2)
+		if err != nil {
+			return nil, err
+		}
+		length, err := d.readWord(2)
+		if err != nil {
+			return nil, err
+		}
+		if start+length > chunkMask || (i > 0 && base+uint(start) <= runs[i-1].maxValue+1) {
+			return nil, fmt.Errorf("%w: roaring run %d overlaps at byte %d", ErrCorrupt, i, d.n)
+		}
+		runs = append(runs, run{base + uint(start), base + uint(start+length)})
+		count += uint(length) + 1
+	}
+	if count != c.cardinality {
+		return nil, fmt.Errorf("%w: roaring run container holds %d values, not %d", ErrCorrupt, count, c.cardinality)
+	}
+	if n == 1 {
+		return NewIntSetFromInterval(runs[0].minValue, runs[0].maxValue), nil
+	}
+	return &IntSet{minValue: runs[0].minValue, maxValue: runs[n-1].maxValue, runs: runs, cardinality: count}, nil
+}
+
+func (d *binaryDecoder) readRoaringArray(c *roaringContainer) (*IntSet, error) {
+	base := c.key << chunkBits
+	set := IntSet{vs: make([]uint64, 1<<chunkBits/64), vsStart: base, cardinality: c.cardinality}
+	for i := uint(0); i < c.cardinality; i++ {
+		x, err := d.readWord(2)
+		if err != nil {
+			return nil, err
+		}
+		v := base + uint(x)
+		if i > 0 && v <= set.maxValue {
+			return nil, fmt.Errorf("%w: roaring array values out of order at byte %d", ErrCorrupt, d.n)
+		}
+		if i == 0 {
+			set.minValue = v
+		}
+		set.maxValue = v
+		set.vs[x>>6] |= 1 << (x & 0x3F)
+	}
+	// keep only the words between the bounds
+	start := (set.minValue - base) >> 6
+	end := (set.maxValue - base) >> 6
+	set.vs = set.vs[start : end+1 : end+1]
+	set.vsStart += start << 6
+	return &set, nil
+}
+
+func (d *binaryDecoder) readRoaringBitmap(c *roaringContainer) (*IntSet, error) {
+	base := c.key << chunkBits
+	set := IntSet{minValue: base, maxValue: base | chunkMask, vs: make([]uint64, 1<<chunkBits/64), vsStart: base, cardinality: c.cardinality}
+	var count int
+	for i := range set.vs {
+		w, err := d.readWord(8)
+		if err != nil {
+			return nil, err
+		}
+		set.vs[i] = w
+		count += bits.OnesCount64(w)
+	}
+	if uint(count) != c.cardinality {
+		return nil, fmt.Errorf("%w: roaring bitmap container holds %d values, not %d", ErrCorrupt, count, c.cardinality)
+	}
+	set.fitBounds()
+	return &set, nil
+}
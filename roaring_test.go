@@ -0,0 +1,150 @@
+package bitset
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+// golden files in testdata/roaring are written by testdata/roaring/gen with
+// the Go Roaring library v1.9.4, and bitmapwith*.bin are copied from its testdata
+var roaringGolden = map[string]func() *IntSet{
+	"empty.bin":    NewIntSet,
+	"interval.bin": func() *IntSet { return NewIntSetFromInterval(100, 200000) },
+	"sparse.bin":   func() *IntSet { return NewIntSetFromUInts([]uint{1, 5, 70000, 4000000000}) },
+	"bitmap.bin": func() *IntSet {
+		set := NewIntSet()
+		for v := uint(0); v <= 60000; v += 3 {
+			set.Add(v)
+		}
+		return set
+	},
+	"mixed.bin": func() *IntSet {
+		set := NewIntSetFromInterval(1000, 5000)
+		for v := uint(1 << 16); v < 1<<16+100; v += 7 {
+			set.Add(v)
+		}
+		for v := uint(2 << 16); v < 3<<16; v += 2 {
+			set.Add(v)
+		}
+		return set
+	},
+	"bitmapwithruns.bin":    newRoaringSpecSet,
+	"bitmapwithoutruns.bin": newRoaringSpecSet,
+}
+
+// the members of the format spec's test bitmaps
+func newRoaringSpecSet() *IntSet {
+	set := NewIntSet()
+	for v := uint(0); v < 100000; v += 1000 {
+		set.Add(v)
+	}
+	for v := uint(100000); v < 200000; v++ {
+		set.Add(3 * v)
+	}
+	for v := uint(700000); v < 800000; v++ {
+		set.Add(v)
+	}
+	return set
+}
+
+func TestRoaringGolden(test *testing.T) {
+	for name, makeSet := range roaringGolden {
+		data, err := os.ReadFile("testdata/roaring/" + name)
+		if err != nil {
+			test.Fatal(err)
+		}
+		set, err := ImportRoaring(bytes.NewReader(data))
+		if err != nil {
+			test.Error("Bad import of", name, ":", err)
+			continue
+		}
+		expected := makeSet()
+		if set.Size() != expected.Size() || set.CountIntersection(expected) != expected.Size() {
+			test.Error("Bad members of", name, ":", set.Size(), "should be", expected.Size())
+		}
+		// containers are imported as they are, so are exported the same way
+		var buf bytes.Buffer
+		if err := set.ExportRoaring(&buf); err != nil {
+			test.Error("Bad export of", name, ":", err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			test.Error("Bad export of", name, ": does not match the golden file")
+		}
+	}
+}
+
+func TestRoaringRoundTrip(test *testing.T) {
+	chunked := newStepSet().Add(1 << 31)
+	sets := []*IntSet{NewIntSetFromInterval(5, 1000000), newRunsSet(), newStepSet(), chunked}
+	for i, set := range sets {
+		var buf bytes.Buffer
+		if err := set.ExportRoaring(&buf); err != nil {
+			test.Error("Bad export of set", i, ":", err)
+			continue
+		}
+		decoded, err := ImportRoaring(&buf)
+		if err != nil {
+			test.Error("Bad import of set", i, ":", err)
+			continue
+		}
+		if decoded.Size() != set.Size() || decoded.CountIntersection(set) != set.Size() {
+			test.Error("Bad round trip of set", i, ":", decoded.String(), "should be", set.String())
+		}
+	}
+	// intervals are written as runs, bitsets as bitmaps
+	var buf bytes.Buffer
+	NewIntSetFromInterval(5, 1000).ExportRoaring(&buf)
+	if buf.Len() != 4+1+4+2+4 {
+		test.Error("Bad interval export length:", buf.Len(), "should be", 4+1+4+2+4)
+	}
+	if err := NewIntSetFromInterval(1<<32, 1<<32+5).ExportRoaring(&buf); !errors.Is(err, ErrRoaringRange) {
+		test.Error("Bad export of large values:", err, "should be", ErrRoaringRange)
+	}
+}
+
+func TestRoaringLowChunk(test *testing.T) {
+	set := NewIntSet()
+	for i := uint(0); i < 5000; i++ {
+		set.Add(i * 1000)
+	}
+	for v := uint(1 << 16); v < 1<<16+20000; v += 2 {
+		set.Add(v)
+	}
+	// the words of the bitset chunk of the second key start in the first
+	chunk := set.chunks[findChunk(set.chunks, 1<<16)]
+	if chunk.vs == nil || chunk.vsStart >= 1<<16 {
+		test.Fatal("Bad test: the chunk of the second key should be a bitset starting below it")
+	}
+	var buf bytes.Buffer
+	if err := set.ExportRoaring(&buf); err != nil {
+		test.Fatal("Bad export:", err)
+	}
+	decoded, err := ImportRoaring(&buf)
+	if err != nil || decoded.Size() != set.Size() || decoded.CountIntersection(set) != set.Size() {
+		test.Error("Bad round trip of a chunk starting below its key:", err)
+	}
+}
+
+func TestRoaringErrors(test *testing.T) {
+	data, _ := os.ReadFile("testdata/roaring/mixed.bin")
+	for _, cut := range []int{0, 3, 9, 20, len(data) - 1} {
+		if _, err := ImportRoaring(bytes.NewReader(data[:cut])); !errors.Is(err, ErrTruncated) {
+			test.Error("Bad import of", cut, "bytes:", err, "should be truncated")
+		}
+	}
+	corrupt := [][]byte{
+		{1, 2, 3, 4},                                           // unknown cookie
+		{0x3A, 0x30, 0, 0, 1, 0, 1, 0},                         // too many containers
+		{0x3B, 0x30, 0, 0, 1, 0, 0, 0, 0, 0, 0},                // run container with no runs
+		{0x3B, 0x30, 0, 0, 1, 0, 0, 1, 0, 1, 0, 0, 0, 5, 0},    // run count mismatch
+		{0x3B, 0x30, 1, 0, 3, 5, 0, 0, 0, 5, 0, 0, 0},          // out of order keys
+		{0x3A, 0x30, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 9, 0, 0, 0}, // wrong offset
+	}
+	for i, data := range corrupt {
+		if _, err := ImportRoaring(bytes.NewReader(data)); !errors.Is(err, ErrCorrupt) {
+			test.Error("Bad import of corrupt data", i, ":", err, "should be corrupt")
+		}
+	}
+}
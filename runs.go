@@ -0,0 +1,438 @@
+package bitset
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// run is a closed interval of values [minValue, maxValue]. A set in run form
+// holds a sorted list of these, none of them touching or overlapping.
+type run struct {
+	minValue uint
+	maxValue uint
+}
+
+func (r run) size() uint {
+	return r.maxValue - r.minValue + 1
+}
+
+// sortRuns sorts runs that may touch or overlap, merging them into the
+// disjoint runs of their union
+func sortRuns(runs []run) []run {
+	sort.Slice(runs, func(i, j int) bool { return runs[i].minValue < runs[j].minValue })
+	sorted := runs[:0]
+	for _, r := range runs {
+		sorted = appendRun(sorted, r)
+	}
+	return sorted
+}
+
+// findRun gets the index of the first run ending at or after x
+func findRun(runs []run, x uint) int {
+	return sort.Search(len(runs), func(i int) bool { return runs[i].maxValue >= x })
+}
+
+// appendRun adds r after the existing runs, merging it into the last run if
+// they touch or overlap. Runs must be appended in order of their minValue.
+func appendRun(runs []run, r run) []run {
+	n := len(runs)
+	if n > 0 {
+		last := &runs[n-1]
+		if last.maxValue == math.MaxUint || r.minValue <= last.maxValue+1 {
+			if r.maxValue > last.maxValue {
+				last.maxValue = r.maxValue
+			}
+			return runs
+		}
+	}
+	return append(runs, r)
+}
+
+func unionRuns(a, b []run) []run {
+	result := make([]run, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if j == len(b) || (i < len(a) && a[i].minValue <= b[j].minValue) {
+			result = appendRun(result, a[i])
+			i++
+		} else {
+			result = appendRun(result, b[j])
+			j++
+		}
+	}
+	return result
+}
+
+func intersectRuns(a, b []run) []run {
+	var result []run
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		lo, hi := a[i].minValue, a[i].maxValue
+		if lo < b[j].minValue {
+			lo = b[j].minValue
+		}
+		if hi > b[j].maxValue {
+			hi = b[j].maxValue
+		}
+		if lo <= hi {
+			result = append(result, run{lo, hi})
+		}
+		if a[i].maxValue < b[j].maxValue {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+func differenceRuns(a, b []run) []run {
+	var result []run
+	j := 0
+	for _, r := range a {
+		// skip anything ending before this run
+		for j < len(b) && b[j].maxValue < r.minValue {
+			j++
+		}
+		lo := r.minValue
+		covered := false
+		for ; j < len(b) && b[j].minValue <= r.maxValue; j++ {
+			if b[j].minValue > lo {
+				result = append(result, run{lo, b[j].minValue - 1})
+			}
+			if b[j].maxValue >= r.maxValue {
+				// this may also cover the next run, so keep j
+				covered = true
+				break
+			}
+			lo = b[j].maxValue + 1
+		}
+		if !covered {
+			result = append(result, run{lo, r.maxValue})
+		}
+	}
+	return result
+}
+
+func symmetricDifferenceRuns(a, b []run) []run {
+	return unionRuns(differenceRuns(a, b), differenceRuns(b, a))
+}
+
+func countRunsIntersection(a, b []run) uint {
+	var count uint
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		lo, hi := a[i].minValue, a[i].maxValue
+		if lo < b[j].minValue {
+			lo = b[j].minValue
+		}
+		if hi > b[j].maxValue {
+			hi = b[j].maxValue
+		}
+		if lo <= hi {
+			count += hi - lo + 1
+		}
+		if a[i].maxValue < b[j].maxValue {
+			i++
+		} else {
+			j++
+		}
+	}
+	return count
+}
+
+// asRuns gets the members of this set as a sorted list of runs. For a set in
+// run form this is its own slice, so must not be modified.
+func (set *IntSet) asRuns() []run {
+	if set.IsEmpty() {
+		return nil
+	}
+	if set.runs != nil {
+		return set.runs
+	}
+	if set.chunks != nil {
+		return set.chunkRuns()
+	}
+	if set.vs == nil {
+		return []run{{set.minValue, set.maxValue}}
+	}
+	return set.bitSetRuns(set.minValue, set.maxValue)
+}
+
+// bitSetRuns scans the words of a bitset for the runs of members between lo and hi
+func (set *IntSet) bitSetRuns(lo, hi uint) []run {
+	var runs []run
+	set.eachBitSetRun(lo, hi, func(r run) bool {
+		runs = append(runs, r)
+		return true
+	})
+	return runs
+}
+
+// eachBitSetRun scans the words of a bitset for the runs of members between lo
+// and hi, calling fn on each until it returns false
+func (set *IntSet) eachBitSetRun(lo, hi uint, fn func(run) bool) bool {
+	start := (lo - set.vsStart) >> 6
+	end := (hi - set.vsStart) >> 6
+	inRun := false
+	var runStart uint
+	for i := start; i <= end; i++ {
+		w := set.vs[i]
+		if i == start {
+			w &= AllBits << (lo & 0x3F)
+		}
+		if i == end {
+			w &= AllBits >> (63 - (hi & 0x3F))
+		}
+		base := set.vsStart + (i << 6)
+		for b := uint(0); b < 64; {
+			if inRun {
+				// look for the end of the run
+				zs := uint(bits.TrailingZeros64(^(w >> b)))
+				if b+zs >= 64 {
+					break // continues into the next word
+				}
+				if !fn(run{runStart, base + b + zs - 1}) {
+					return false
+				}
+				inRun = false
+				b += zs
+			} else {
+				if w>>b == 0 {
+					break
+				}
+				b += uint(bits.TrailingZeros64(w >> b))
+				runStart = base + b
+				inRun = true
+			}
+		}
+	}
+	if inRun {
+		return fn(run{runStart, hi})
+	}
+	return true
+}
+
+// setRuns replaces the members of this set with the given sorted, disjoint
+// runs, using the interval form for a single run.
+func (set *IntSet) setRuns(runs []run) *IntSet {
+	set.shared, set.borrowed = false, false // dropped rather than changed, so not copied
+	set.modified()
+	set.vs = nil
+	set.vsStart = 0
+	set.chunks = nil // a chunked set gives up its chunks too
+	set.cardinalityInvalidated = false
+	switch len(runs) {
+	case 0:
+		set.runs = nil
+		set.minValue = math.MaxUint
+		set.maxValue = 0
+		set.cardinality = 0
+	case 1:
+		set.runs = nil
+		set.minValue = runs[0].minValue
+		set.maxValue = runs[0].maxValue
+		set.cardinality = runs[0].size()
+	default:
+		set.runs = runs
+		set.minValue = runs[0].minValue
+		set.maxValue = runs[len(runs)-1].maxValue
+		set.cardinality = 0
+		for _, r := range runs {
+			set.cardinality += r.size()
+		}
+		set.fitRuns()
+	}
+	return set
+}
+
+// fitRuns promotes a set in run form to a bitset once two words per run is
+// more than the bitset would take, or to chunks if it spans too many values
+// for a bitset and has too many runs, unless its runs are long enough to
+// cover many more chunks than there are runs
+func (set *IntSet) fitRuns() {
+	span := set.maxValue - set.minValue
+	if span >= maxBitSetSpan {
+		if n := uint(len(set.runs)); n > maxRuns && runKeys(set.runs, 2*n) <= 2*n {
+			set.promoteToChunks()
+		}
+		return
+	}
+	if uint(len(set.runs)) > span>>7 {
+		set.promoteToBitSet()
+	}
+}
+
+func (set *IntSet) addToRuns(x uint) *IntSet {
+	i := findRun(set.runs, x)
+	if i < len(set.runs) && set.runs[i].minValue <= x {
+		return set // already in a run
+	}
+	joinPrev := i > 0 && set.runs[i-1].maxValue+1 == x
+	joinNext := i < len(set.runs) && set.runs[i].minValue-1 == x
+	switch {
+	case joinPrev && joinNext:
+		set.runs[i-1].maxValue = set.runs[i].maxValue
+		set.runs = append(set.runs[:i], set.runs[i+1:]...)
+	case joinPrev:
+		set.runs[i-1].maxValue = x
+	case joinNext:
+		set.runs[i].minValue = x
+	default:
+		set.runs = append(set.runs, run{})
+		copy(set.runs[i+1:], set.runs[i:])
+		set.runs[i] = run{x, x}
+	}
+	set.cardinality++
+	if x < set.minValue {
+		set.minValue = x
+	}
+	if x > set.maxValue {
+		set.maxValue = x
+	}
+	if len(set.runs) == 1 {
+		// the gap has been filled
+		set.runs = nil
+		return set
+	}
+	set.fitRuns()
+	return set
+}
+
+func (set *IntSet) removeFromRuns(x uint) *IntSet {
+	i := findRun(set.runs, x)
+	if i == len(set.runs) || set.runs[i].minValue > x {
+		return set // in a gap
+	}
+	r := set.runs[i]
+	switch {
+	case r.minValue == r.maxValue:
+		set.runs = append(set.runs[:i], set.runs[i+1:]...)
+	case x == r.minValue:
+		set.runs[i].minValue++
+	case x == r.maxValue:
+		set.runs[i].maxValue--
+	default:
+		// split the run in two
+		set.runs = append(set.runs, run{})
+		copy(set.runs[i+1:], set.runs[i:])
+		set.runs[i].maxValue = x - 1
+		set.runs[i+1].minValue = x + 1
+	}
+	set.cardinality--
+	set.minValue = set.runs[0].minValue
+	set.maxValue = set.runs[len(set.runs)-1].maxValue
+	if len(set.runs) == 1 {
+		set.runs = nil
+		return set
+	}
+	set.fitRuns()
+	return set
+}
+
+// countIntersectionRuns counts the intersection where at least one set is in run form
+func (set *IntSet) countIntersectionRuns(other *IntSet) uint {
+	if set.vs == nil && other.vs == nil {
+		return countRunsIntersection(set.asRuns(), other.asRuns())
+	}
+	if set.vs == nil {
+		// count bits of the bitset under each run
+		set, other = other, set
+	}
+	minV, maxV := set.intersectMinMax(other)
+	if minV > maxV {
+		return 0
+	}
+	var count uint
+	for i := findRun(other.runs, minV); i < len(other.runs); i++ {
+		lo, hi := other.runs[i].minValue, other.runs[i].maxValue
+		if lo > maxV {
+			break
+		}
+		if lo < minV {
+			lo = minV
+		}
+		if hi > maxV {
+			hi = maxV
+		}
+		count += set.countBitRange(lo, hi)
+	}
+	return count
+}
+
+// intersectionRuns intersects sets where at least one is in run form
+func (set *IntSet) intersectionRuns(other *IntSet) *IntSet {
+	if set.vs != nil {
+		minV, maxV := set.intersectMinMax(other)
+		if minV > maxV {
+			return set.Clear()
+		}
+		// clear the gaps around the other set's runs
+		lo := set.minValue
+		count := set.Size()
+		covered := false
+		for i := findRun(other.runs, lo); i < len(other.runs) && other.runs[i].minValue <= set.maxValue; i++ {
+			r := other.runs[i]
+			if r.minValue > lo {
+				count -= set.countBitRange(lo, r.minValue-1)
+				set.clearBitRange(lo, r.minValue-1)
+			}
+			if r.maxValue >= set.maxValue {
+				covered = true
+				break
+			}
+			lo = r.maxValue + 1
+		}
+		if !covered {
+			count -= set.countBitRange(lo, set.maxValue)
+			set.clearBitRange(lo, set.maxValue)
+		}
+		set.minValue = minV
+		set.maxValue = maxV
+		set.cardinality = count
+		set.fitBounds()
+		return set
+	}
+	if other.vs != nil {
+		// only the part overlapping the other's bitset can remain, then
+		// intersect as a bitset
+		set.setRuns(intersectRuns(set.asRuns(), []run{{other.minValue, other.maxValue}}))
+		if set.runs != nil {
+			set.promoteToBitSet()
+		}
+		return set.Intersection(other)
+	}
+	return set.setRuns(intersectRuns(set.asRuns(), other.asRuns()))
+}
+
+// differenceRuns removes from this set the values of other, where at least one
+// set is in run form or the other is a bitset. minV and maxV bound their intersection.
+func (set *IntSet) differenceRuns(other *IntSet, minV, maxV uint) *IntSet {
+	if set.vs != nil {
+		// clear the other set's runs from the bitset
+		runs := other.runs
+		count := set.Size()
+		for i := findRun(runs, minV); i < len(runs) && runs[i].minValue <= maxV; i++ {
+			lo, hi := runs[i].minValue, runs[i].maxValue
+			if lo < minV {
+				lo = minV
+			}
+			if hi > maxV {
+				hi = maxV
+			}
+			count -= set.countBitRange(lo, hi)
+			set.clearBitRange(lo, hi)
+		}
+		set.cardinality = count
+		set.fitBounds()
+		return set
+	}
+	var otherRuns []run
+	if other.vs != nil {
+		otherRuns = other.bitSetRuns(minV, maxV)
+	} else {
+		otherRuns = other.asRuns()
+	}
+	return set.setRuns(differenceRuns(set.asRuns(), otherRuns))
+}
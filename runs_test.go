@@ -0,0 +1,196 @@
+package bitset
+
+import (
+	"testing"
+)
+
+// a few long runs spread between 0 and 3000
+func newRunsSet() *IntSet {
+	set := NewIntSet()
+	for _, start := range []uint{100, 700, 1500, 2200, 2900} {
+		for i := start; i < start+50; i++ {
+			set.Add(i)
+		}
+	}
+	return set
+}
+
+func newStepSet() *IntSet {
+	set := NewIntSet()
+	for i := 1001; i < 3000; i += 5 {
+		set.Add(uint(i))
+	}
+	return set
+}
+
+// newFormSets gets makers of a set in each form, keyed by the form, which
+// tests can add their own makers to
+func newFormSets() map[string]func() *IntSet {
+	return map[string]func() *IntSet{
+		"empty":    NewIntSet,
+		"interval": func() *IntSet { return NewIntSetFromInterval(1500, 2500) },
+		"runs":     newRunsSet,
+		"bitset":   newStepSet,
+		"chunks":   newChunkedSet,
+	}
+}
+
+func TestRunsAdd(test *testing.T) {
+	set := NewIntSet()
+	for k := uint(0); k < 20; k++ {
+		for i := uint(0); i < 100; i++ {
+			set.Add(k*1000000 + i)
+		}
+	}
+	if set.vs != nil {
+		test.Error("Promoted to a bit set:", len(set.vs), "words")
+	}
+	if len(set.runs) != 20 {
+		test.Error("Bad run count:", len(set.runs), "should be 20")
+	}
+	if set.Size() != 2000 {
+		test.Error("Bad count:", set.Size(), "should be 2000")
+	}
+	if !set.Contains(5000099) || set.Contains(5000100) || set.Contains(4999999) {
+		test.Error("Bad members:", set.String())
+	}
+	// filling a gap joins two runs
+	set = NewIntSet()
+	set.Add(10).Add(1000).Add(12).Add(11)
+	if len(set.runs) != 2 || set.Size() != 4 {
+		test.Error("Bad join:", set.runs, "should be two runs")
+	}
+}
+
+func TestRunsRemove(test *testing.T) {
+	set := NewIntSetFromInterval(0, 9999)
+	set.Remove(5000)
+	if set.vs != nil || len(set.runs) != 2 {
+		test.Error("Bad split:", set.runs, "should be two runs")
+	}
+	set.Remove(0).Remove(9999).Remove(5001)
+	if set.Size() != 9996 {
+		test.Error("Bad count:", set.Size(), "should be 9996")
+	}
+	if set.Contains(5000) || set.Contains(5001) || !set.Contains(4999) || !set.Contains(5002) {
+		test.Error("Bad members after remove")
+	}
+	// removing the smaller run reverts to an interval
+	for i := uint(1); i < 5000; i++ {
+		set.Remove(i)
+	}
+	if set.runs != nil || set.vs != nil {
+		test.Error("Should be an interval:", set.runs)
+	}
+	if ok, first := set.GetFirstValue(); !ok || first != 5002 {
+		test.Error("Bad first value:", first, "should be 5002")
+	}
+}
+
+func TestRunsIter(test *testing.T) {
+	set := newRunsSet()
+	members := make([]uint, 0, 250)
+	for i := uint(0); i < 3000; i++ {
+		if set.Contains(i) {
+			members = append(members, i)
+		}
+	}
+	if len(members) != 250 {
+		test.Error("Bad count:", len(members), "should be 250")
+	}
+	i := 0
+	for ok, v := set.GetFirstValue(); ok; ok, v = set.GetNextValue(v) {
+		if v != members[i] {
+			test.Error("Bad ID:", v, "should be", members[i])
+			break
+		}
+		i++
+	}
+	i = len(members) - 1
+	for ok, v := set.GetLastValue(); ok; ok, v = set.GetPrevValue(v) {
+		if v != members[i] {
+			test.Error("Bad ID:", v, "should be", members[i], "at index", i)
+			break
+		}
+		i--
+	}
+	if i != -1 {
+		test.Error("Bad count:", i, "should be", -1)
+	}
+}
+
+func TestRunsBinaryOps(test *testing.T) {
+	makers := newFormSets()
+	// members are only checked up to 3500
+	delete(makers, "chunks")
+	ops := []struct {
+		name string
+		op   func(a, b *IntSet) *IntSet
+		keep func(inA, inB bool) bool
+	}{
+		{"union", (*IntSet).Union, func(inA, inB bool) bool { return inA || inB }},
+		{"intersection", (*IntSet).Intersection, func(inA, inB bool) bool { return inA && inB }},
+		{"difference", (*IntSet).Difference, func(inA, inB bool) bool { return inA && !inB }},
+	}
+	for nameA, makeA := range makers {
+		for nameB, makeB := range makers {
+			if nameA != "runs" && nameB != "runs" {
+				continue
+			}
+			a, b := makeA(), makeB()
+			var count uint
+			for i := uint(0); i < 3500; i++ {
+				if a.Contains(i) && b.Contains(i) {
+					count++
+				}
+			}
+			if found := a.CountIntersection(b); found != count {
+				test.Error("Bad intersection count for", nameA, nameB, ":", found, "should be", count)
+			}
+			for _, o := range ops {
+				result := o.op(makeA(), b)
+				var size uint
+				for i := uint(0); i < 3500; i++ {
+					expected := o.keep(a.Contains(i), b.Contains(i))
+					if expected {
+						size++
+					}
+					if result.Contains(i) != expected {
+						test.Error("Bad", o.name, "of", nameA, nameB, ":", i, "should be", expected)
+						break
+					}
+				}
+				if result.Size() != size {
+					test.Error("Bad", o.name, "count of", nameA, nameB, ":", result.Size(), "should be", size)
+				}
+			}
+		}
+	}
+}
+
+func TestRunsUnionIntervals(test *testing.T) {
+	set := NewIntSetFromInterval(1, 5000).Union(NewIntSetFromInterval(10000, 20000))
+	if len(set.runs) != 2 || set.Size() != 15001 {
+		test.Error("Bad union:", set.String(), "should be two runs of 15001 values")
+	}
+	if set.Contains(7000) {
+		test.Error("Bad union: should not contain 7000")
+	}
+	set.Union(NewIntSetFromInterval(5001, 9999))
+	if set.runs != nil || set.Size() != 20000 {
+		test.Error("Bad union:", set.String(), "should be an interval of 20000 values")
+	}
+}
+
+func TestSetRunsOnChunks(test *testing.T) {
+	// a chunked set given runs gives up its chunks
+	set := newChunkedSet()
+	set.setRuns([]run{{1, 5}, {1 << 30, 1<<30 + 10}})
+	if set.chunks != nil || set.Size() != 16 || !set.Contains(1<<30+5) || set.Contains(7) {
+		test.Error("Bad runs set on a chunked set:", len(set.chunks), "chunks and", set.Size(), "members")
+	}
+	set = NewIntSetFromUInts([]uint{5, 7, 4000000000}).Optimize()
+	if set.chunks != nil || set.runs == nil || set.Size() != 3 {
+		test.Error("Bad optimize of a small chunked set:", len(set.chunks), "chunks and", set.Size(), "members")
+	}
+}
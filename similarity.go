@@ -0,0 +1,159 @@
+package bitset
+
+import (
+	"math/bits"
+)
+
+// CountUnion counts the members of either set, leaving both unchanged
+func (set *IntSet) CountUnion(other *IntSet) uint {
+	sizeA, sizeB, both := set.countOverlap(other)
+	return sizeA + sizeB - both
+}
+
+// CountDifference counts the members of this set that are not in other,
+// leaving both unchanged
+func (set *IntSet) CountDifference(other *IntSet) uint {
+	sizeA, _, both := set.countOverlap(other)
+	return sizeA - both
+}
+
+// CountSymmetricDifference counts the members of exactly one of the sets,
+// leaving both unchanged
+func (set *IntSet) CountSymmetricDifference(other *IntSet) uint {
+	sizeA, sizeB, both := set.countOverlap(other)
+	return sizeA + sizeB - 2*both
+}
+
+// Jaccard gets the size of the intersection of a and b over the size of their
+// union. Two empty sets are taken to be identical.
+func Jaccard(a, b *IntSet) float64 {
+	sizeA, sizeB, both := a.countOverlap(b)
+	if sizeA+sizeB == 0 {
+		return 1
+	}
+	return float64(both) / float64(sizeA+sizeB-both)
+}
+
+// Dice gets twice the size of the intersection of a and b over the sum of
+// their sizes. Two empty sets are taken to be identical.
+func Dice(a, b *IntSet) float64 {
+	sizeA, sizeB, both := a.countOverlap(b)
+	if sizeA+sizeB == 0 {
+		return 1
+	}
+	return 2 * float64(both) / float64(sizeA+sizeB)
+}
+
+// Overlap gets the size of the intersection of a and b over the size of the
+// smaller set, which is 1 when one is a subset of the other. Two empty sets are
+// taken to be identical, and an empty set shares nothing with any other.
+func Overlap(a, b *IntSet) float64 {
+	sizeA, sizeB, both := a.countOverlap(b)
+	switch {
+	case sizeA+sizeB == 0:
+		return 1
+	case sizeA == 0 || sizeB == 0:
+		return 0
+	}
+	return float64(both) / float64(min(sizeA, sizeB))
+}
+
+// HammingDistance counts the values that are members of exactly one of a and b
+func HammingDistance(a, b *IntSet) uint {
+	return a.CountSymmetricDifference(b)
+}
+
+// countOverlap counts the members of each set and of their intersection
+// without changing either or trusting a cached count. Where both are bitsets
+// the overlapping words are read once for all three counts.
+func (set *IntSet) countOverlap(other *IntSet) (uint, uint, uint) {
+	if set.chunks != nil || other.chunks != nil {
+		return set.countOverlapChunks(other)
+	}
+	if set.vs == nil || other.vs == nil || set.IsEmpty() || other.IsEmpty() {
+		return set.countExact(), other.countExact(), set.CountIntersection(other)
+	}
+	// word indexes from zero, so both sets share them
+	startA, endA := set.minValue>>6, set.maxValue>>6+1
+	startB, endB := other.minValue>>6, other.maxValue>>6+1
+	start, end := max(startA, startB), min(endA, endB)
+	if start >= end {
+		return set.countWords(startA, endA), other.countWords(startB, endB), 0
+	}
+	sizeA := set.countWords(startA, start) + set.countWords(end, endA)
+	sizeB := other.countWords(startB, start) + other.countWords(end, endB)
+	var both uint
+	offsetA, offsetB := set.vsStart>>6, other.vsStart>>6
+	for i := start; i < end; i++ {
+		a, b := set.vs[i-offsetA], other.vs[i-offsetB]
+		sizeA += uint(bits.OnesCount64(a))
+		sizeB += uint(bits.OnesCount64(b))
+		both += uint(bits.OnesCount64(a & b))
+	}
+	return sizeA, sizeB, both
+}
+
+// countOverlapChunks counts chunk by chunk where at least one set is chunked
+func (set *IntSet) countOverlapChunks(other *IntSet) (uint, uint, uint) {
+	if limit := uint(len(set.chunks) + len(other.chunks)); set.splitsWide(limit) || other.splitsWide(limit) {
+		// count by runs rather than split a long interval or run into chunks
+		return set.countExact(), other.countExact(), countRunsIntersection(set.asRuns(), other.asRuns())
+	}
+	own, others := set.chunks, other.chunks
+	if own == nil {
+		own = set.splitChunks()
+	}
+	if others == nil {
+		others = other.splitChunks()
+	}
+	var sizeA, sizeB, both uint
+	i, j := 0, 0
+	for i < len(own) || j < len(others) {
+		if j == len(others) || (i < len(own) && chunkKey(own[i].minValue) < chunkKey(others[j].minValue)) {
+			sizeA += own[i].countExact()
+			i++
+		} else if i == len(own) || chunkKey(others[j].minValue) < chunkKey(own[i].minValue) {
+			sizeB += others[j].countExact()
+			j++
+		} else {
+			a, b, ab := own[i].countOverlap(others[j])
+			sizeA, sizeB, both = sizeA+a, sizeB+b, both+ab
+			i++
+			j++
+		}
+	}
+	return sizeA, sizeB, both
+}
+
+// countExact counts the members of a set from its runs or words, without
+// using or updating the cached count
+func (set *IntSet) countExact() uint {
+	switch {
+	case set.IsEmpty():
+		return 0
+	case set.chunks != nil:
+		var count uint
+		for _, chunk := range set.chunks {
+			count += chunk.countExact()
+		}
+		return count
+	case set.vs != nil:
+		return set.countWords(set.minValue>>6, set.maxValue>>6+1)
+	}
+	var count uint
+	for _, r := range set.asRuns() {
+		count += r.maxValue - r.minValue + 1
+	}
+	return count
+}
+
+// countWords counts the bits of a bitset from word start up to but not
+// including word end, indexing words from zero rather than from vsStart
+func (set *IntSet) countWords(start, end uint) uint {
+	var count int
+	offset := set.vsStart >> 6
+	for i := start; i < end; i++ {
+		count += bits.OnesCount64(set.vs[i-offset])
+	}
+	return uint(count)
+}
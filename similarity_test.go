@@ -0,0 +1,99 @@
+package bitset
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestSimilarity(test *testing.T) {
+	valuesA := newChunkedValues(3)
+	makers := newFormSets()
+	makers["shifted"] = func() *IntSet { return NewIntSetFromUInts([]uint{900, 1006, 1011, 4000, 4001, 4100}) }
+	makers["far"] = func() *IntSet { return NewIntSetFromUInts([]uint{1 << 21, 1<<21 + 3, 1<<21 + 64}) }
+	makers["chunks"] = func() *IntSet { return NewIntSetFromUInts(valuesA) }
+	makers["chunks2"] = func() *IntSet {
+		return NewIntSetFromUInts(append(newStepSet().AsUints(), valuesA[:3000]...))
+	}
+	for nameA, makeA := range makers {
+		for nameB, makeB := range makers {
+			a, b := makeA(), makeB()
+			inA, inB := map[uint]bool{}, map[uint]bool{}
+			for v := range a.All() {
+				inA[v] = true
+			}
+			for v := range b.All() {
+				inB[v] = true
+			}
+			var both uint
+			for v := range inA {
+				if inB[v] {
+					both++
+				}
+			}
+			sizeA, sizeB := uint(len(inA)), uint(len(inB))
+			dataA, _ := a.MarshalBinary()
+			dataB, _ := b.MarshalBinary()
+
+			if count := a.CountUnion(b); count != sizeA+sizeB-both {
+				test.Error("Bad union count of", nameA, nameB, ":", count, "should be", sizeA+sizeB-both)
+			}
+			if count := a.CountDifference(b); count != sizeA-both {
+				test.Error("Bad difference count of", nameA, nameB, ":", count, "should be", sizeA-both)
+			}
+			if count := a.CountSymmetricDifference(b); count != sizeA+sizeB-2*both {
+				test.Error("Bad symmetric difference count of", nameA, nameB, ":", count, "should be", sizeA+sizeB-2*both)
+			}
+			if count := HammingDistance(a, b); count != sizeA+sizeB-2*both {
+				test.Error("Bad Hamming distance of", nameA, nameB, ":", count, "should be", sizeA+sizeB-2*both)
+			}
+			jaccard, dice, overlap := 1.0, 1.0, 1.0
+			if sizeA+sizeB > 0 {
+				jaccard = float64(both) / float64(sizeA+sizeB-both)
+				dice = 2 * float64(both) / float64(sizeA+sizeB)
+				overlap = 0
+				if sizeA > 0 && sizeB > 0 {
+					overlap = float64(both) / float64(min(sizeA, sizeB))
+				}
+			}
+			if value := Jaccard(a, b); math.Abs(value-jaccard) > 1e-12 {
+				test.Error("Bad Jaccard of", nameA, nameB, ":", value, "should be", jaccard)
+			}
+			if value := Dice(a, b); math.Abs(value-dice) > 1e-12 {
+				test.Error("Bad Dice of", nameA, nameB, ":", value, "should be", dice)
+			}
+			if value := Overlap(a, b); math.Abs(value-overlap) > 1e-12 {
+				test.Error("Bad overlap of", nameA, nameB, ":", value, "should be", overlap)
+			}
+
+			after, _ := a.MarshalBinary()
+			if !bytes.Equal(after, dataA) {
+				test.Error("Bad change to", nameA, "in counts with", nameB)
+			}
+			after, _ = b.MarshalBinary()
+			if !bytes.Equal(after, dataB) {
+				test.Error("Bad change to", nameB, "in counts with", nameA)
+			}
+		}
+	}
+}
+
+func TestSimilarityStaleSize(test *testing.T) {
+	set := newStepSet()
+	set.cardinality = 1 // a stale cached count
+	other := NewIntSetFromInterval(1001, 1500)
+	if count := set.CountUnion(other); count != 500+300 {
+		test.Error("Bad union count with a stale size:", count, "should be", 800)
+	}
+}
+
+func TestSimilarityWide(test *testing.T) {
+	set := newChunkedSet()
+	interval := NewIntSetFromInterval(0, 1<<44)
+	if count := set.CountUnion(interval); count != 1<<44+1 {
+		test.Error("Bad union count with a wide interval:", count, "should be", uint(1<<44+1))
+	}
+	if count := interval.CountDifference(set); count != 1<<44+1-set.Size() {
+		test.Error("Bad difference count with a wide interval:", count, "should be", 1<<44+1-set.Size())
+	}
+}
@@ -0,0 +1,66 @@
+// Command gen writes the golden Roaring files in its parent directory, using
+// the Go Roaring library at the version in go.mod. Run it from this directory
+// with go run . and it also copies bitmapwithruns.bin and bitmapwithoutruns.bin,
+// the test bitmaps of the format spec, from the library's own testdata.
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/RoaringBitmap/roaring"
+)
+
+func write(name string, rb *roaring.Bitmap) {
+	data, err := rb.ToBytes()
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(filepath.Join("..", name), data, 0644); err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	write("empty.bin", roaring.New())
+
+	rb := roaring.New()
+	rb.AddRange(100, 200001)
+	rb.RunOptimize()
+	write("interval.bin", rb)
+
+	write("sparse.bin", roaring.BitmapOf(1, 5, 70000, 4000000000))
+
+	rb = roaring.New()
+	for v := uint32(0); v <= 60000; v += 3 {
+		rb.Add(v)
+	}
+	write("bitmap.bin", rb)
+
+	rb = roaring.New()
+	rb.AddRange(1000, 5001)
+	for v := uint32(1 << 16); v < 1<<16+100; v += 7 {
+		rb.Add(v)
+	}
+	for v := uint32(2 << 16); v < 3<<16; v += 2 {
+		rb.Add(v)
+	}
+	rb.RunOptimize()
+	write("mixed.bin", rb)
+
+	dir, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/RoaringBitmap/roaring").Output()
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range []string{"bitmapwithruns.bin", "bitmapwithoutruns.bin"} {
+		data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(dir)), "testdata", name))
+		if err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(filepath.Join("..", name), data, 0644); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package bitset
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseIntSet reads a set from a comma separated list of values and ranges,
+// as written by Format, such as "1-5,8,10-20". Spaces around each value are
+// ignored, and values and ranges may be in any order. The empty string is the
+// empty set.
+func ParseIntSet(s string) (*IntSet, error) {
+	if strings.TrimSpace(s) == "" {
+		return NewIntSet(), nil
+	}
+	parts := strings.Split(s, ",")
+	runs := make([]run, 0, len(parts))
+	for _, part := range parts {
+		lo, hi := part, part
+		if i := strings.IndexByte(part, '-'); i >= 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		var r run
+		var err error
+		if r.minValue, err = parseTextValue(lo); err != nil {
+			return nil, err
+		}
+		if r.maxValue, err = parseTextValue(hi); err != nil {
+			return nil, err
+		}
+		if r.maxValue < r.minValue {
+			return nil, fmt.Errorf("bitset: range %q ends before it starts", strings.TrimSpace(part))
+		}
+		runs = append(runs, r)
+	}
+	return NewIntSet().setRuns(sortRuns(runs)), nil
+}
+
+func parseTextValue(s string) (uint, error) {
+	s = strings.TrimSpace(s)
+	x, err := strconv.ParseUint(s, 10, strconv.IntSize)
+	if err != nil {
+		return 0, fmt.Errorf("bitset: %q is not an unsigned integer", s)
+	}
+	return uint(x), nil
+}
+
+// Format writes all members of the set as a comma separated list of values
+// and ranges, such as "1-5,8,10-20", which ParseIntSet reads back. Runs of
+// two values are written as a range.
+func (set *IntSet) Format() string {
+	return string(set.appendText(nil))
+}
+
+func (set *IntSet) appendText(buf []byte) []byte {
+	for i, r := range set.asRuns() {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendUint(buf, uint64(r.minValue), 10)
+		if r.maxValue != r.minValue {
+			buf = append(buf, '-')
+			buf = strconv.AppendUint(buf, uint64(r.maxValue), 10)
+		}
+	}
+	return buf
+}
+
+// MarshalText implements encoding.TextMarshaler, writing the set as Format does
+func (set *IntSet) MarshalText() ([]byte, error) {
+	return set.appendText(nil), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, replacing the members of
+// this set with those read by ParseIntSet. The set is left unchanged on error.
+func (set *IntSet) UnmarshalText(text []byte) error {
+	parsed, err := ParseIntSet(string(text))
+	if err != nil {
+		return err
+	}
+	set.become(parsed)
+	return nil
+}
+
+// Set implements flag.Value along with String, replacing the members of this
+// set with those read by ParseIntSet
+func (set *IntSet) Set(s string) error {
+	return set.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,48 @@
+package bitset
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestParseIntSet(test *testing.T) {
+	set, err := ParseIntSet(" 10-20, 8,1-5 ,4-6")
+	if err != nil {
+		test.Error("Bad parse:", err)
+	}
+	if s := set.Format(); s != "1-6,8,10-20" {
+		test.Error("Bad format:", s, "should be 1-6,8,10-20")
+	}
+	if set, err = ParseIntSet(""); err != nil || !set.IsEmpty() || set.Format() != "" {
+		test.Error("Bad parse of the empty set:", set.String(), err)
+	}
+	for _, s := range []string{"1,", "-5", "5-", "1-2-3", "a", "5-1", "1.5", "1,,2", "18446744073709551616"} {
+		if _, err := ParseIntSet(s); err == nil {
+			test.Error("Bad parse of", s, ": should be an error")
+		}
+	}
+	for _, set := range []*IntSet{newStepSet(), newRunsSet(), newChunkedSet(), NewIntSetFromInterval(0, 1000)} {
+		parsed, err := ParseIntSet(set.Format())
+		if err != nil || parsed.Size() != set.Size() || parsed.CountIntersection(set) != set.Size() {
+			test.Error("Bad round trip:", parsed.String(), "should be", set.String(), err)
+		}
+	}
+}
+
+func TestTextFlag(test *testing.T) {
+	set := NewIntSetFromInterval(1, 2)
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(set, "ids", "the ids")
+	if err := flags.Parse([]string{"-ids", "3-5,9"}); err != nil {
+		test.Error("Bad flag:", err)
+	}
+	if set.Format() != "3-5,9" {
+		test.Error("Bad flag value:", set.Format(), "should be 3-5,9")
+	}
+	if err := set.UnmarshalText([]byte("3,x")); err == nil || set.Format() != "3-5,9" {
+		test.Error("Bad set after failed unmarshal:", set.Format(), "should be unchanged")
+	}
+	if text, _ := set.MarshalText(); string(text) != "3-5,9" {
+		test.Error("Bad text:", string(text), "should be 3-5,9")
+	}
+}
@@ -0,0 +1,192 @@
+package bitset
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// AtLeast gets a new set of the values that are members of at least k of the
+// given sets. The sets are counted a word at a time, three at a time: a
+// carry-save adder reduces the words of three sets to a sum and a carry, which
+// are then added into bit-sliced counters. Intervals and runs are added as word
+// masks without being promoted to bitsets. A k of zero or less counts as one,
+// giving the union of the sets.
+func AtLeast(k int, sets ...*IntSet) *IntSet {
+	nonEmpty := make([]*IntSet, 0, len(sets))
+	for _, set := range sets {
+		if !set.IsEmpty() {
+			nonEmpty = append(nonEmpty, set)
+		}
+	}
+	switch {
+	case k > len(nonEmpty) || len(nonEmpty) == 0:
+		return NewIntSet()
+	case k <= 1:
+		return UnionAll(nonEmpty...)
+	case k == len(nonEmpty):
+		return IntersectAll(nonEmpty...)
+	}
+	lo, hi := nonEmpty[0].minValue, nonEmpty[0].maxValue
+	flat, chunked := true, false
+	for _, set := range nonEmpty {
+		lo, hi = rangeUnion(lo, hi, set.minValue, set.maxValue)
+		flat = flat && set.vs == nil && set.chunks == nil
+		chunked = chunked || set.chunks != nil
+	}
+	if flat {
+		return NewIntSet().setRuns(atLeastRuns(k, nonEmpty))
+	}
+	if chunked || hi-lo >= maxBitSetSpan {
+		return atLeastChunks(k, nonEmpty)
+	}
+
+	// counters[j] holds bit j of the count for each value
+	n := (hi >> 6) - (lo >> 6) + 1
+	base := lo &^ 0x3F
+	counters := make([][]uint64, bits.Len(uint(len(nonEmpty))))
+	for j := range counters {
+		counters[j] = make([]uint64, n)
+	}
+	// add adds x at weight 1<<j to the counts of the values of word i
+	add := func(j int, i uint, x uint64) {
+		for ; x != 0; j++ {
+			carry := counters[j][i] & x
+			counters[j][i] ^= x
+			x = carry
+		}
+	}
+	// sets are taken three at a time, and a carry-save adder reduces each word
+	// of the three to a sum of weight one and a carry of weight two
+	var group [3][]uint64
+	for g := range group {
+		group[g] = make([]uint64, n)
+	}
+	for g := 0; g < len(nonEmpty); g += len(group) {
+		first, last := n, uint(0)
+		for i, set := range nonEmpty[g:min(g+len(group), len(nonEmpty))] {
+			start, end := set.fillWords(group[i], base)
+			first, last = min(first, start), max(last, end)
+		}
+		for i := first; i <= last; i++ {
+			a, b, c := group[0][i], group[1][i], group[2][i]
+			add(0, i, a^b^c)
+			add(1, i, a&b|c&(a^b))
+			group[0][i], group[1][i], group[2][i] = 0, 0, 0
+		}
+	}
+
+	// compare each count with k from the highest bit down
+	result := &IntSet{minValue: lo, maxValue: hi, vsStart: base, vs: make([]uint64, n)}
+	for i := range result.vs {
+		var greater uint64
+		equal := AllBits
+		for j := len(counters) - 1; j >= 0; j-- {
+			if k&(1<<j) != 0 {
+				equal &= counters[j][i]
+			} else {
+				greater |= equal & counters[j][i]
+				equal &^= counters[j][i]
+			}
+		}
+		result.vs[i] = greater | equal
+	}
+	result.vs[0] &= AllBits << (lo & 0x3F)
+	result.vs[n-1] &= AllBits >> (63 - (hi & 0x3F))
+	result.trimBitSet()
+	return result
+}
+
+// fillWords sets the bits of the members of this bitset, interval or run set
+// in words, where words[0] starts at base, getting the first and last words
+// written. Intervals and runs are written as masks, without being promoted.
+func (set *IntSet) fillWords(words []uint64, base uint) (uint, uint) {
+	if set.vs != nil {
+		start := (set.minValue - set.vsStart) >> 6
+		end := (set.maxValue - set.vsStart) >> 6
+		offset := (set.vsStart + start<<6 - base) >> 6
+		copy(words[offset:], set.vs[start:end+1])
+		return offset, offset + end - start
+	}
+	for _, r := range set.asRuns() {
+		start := (r.minValue - base) >> 6
+		end := (r.maxValue - base) >> 6
+		startMask := AllBits << (r.minValue & 0x3F)
+		endMask := AllBits >> (63 - (r.maxValue & 0x3F))
+		if start == end {
+			words[start] |= startMask & endMask
+			continue
+		}
+		words[start] |= startMask
+		for i := start + 1; i < end; i++ {
+			words[i] = AllBits
+		}
+		words[end] |= endMask
+	}
+	return (set.minValue - base) >> 6, (set.maxValue - base) >> 6
+}
+
+// atLeastRuns sweeps over the starts and ends of the runs of intervals and run
+// sets, keeping the runs where at least k overlap
+func atLeastRuns(k int, sets []*IntSet) []run {
+	type edge struct {
+		value uint
+		delta int
+	}
+	var edges []edge
+	for _, set := range sets {
+		for _, r := range set.asRuns() {
+			edges = append(edges, edge{r.minValue, 1})
+			if r.maxValue != ^uint(0) {
+				edges = append(edges, edge{r.maxValue + 1, -1})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].value < edges[j].value })
+	var runs []run
+	count := 0
+	for i := 0; i < len(edges); {
+		// apply all edges at this value together
+		v := edges[i].value
+		before := count
+		for ; i < len(edges) && edges[i].value == v; i++ {
+			count += edges[i].delta
+		}
+		if before < k && count >= k {
+			runs = append(runs, run{v, ^uint(0)})
+		} else if before >= k && count < k {
+			runs[len(runs)-1].maxValue = v - 1
+		}
+	}
+	return runs
+}
+
+// atLeastChunks counts chunk by chunk, where at least one set is chunked or
+// they span too many values for a bitset
+func atLeastChunks(k int, sets []*IntSet) *IntSet {
+	if wideChunks(sets) {
+		return NewIntSet().setRuns(atLeastRuns(k, sets))
+	}
+	var chunks []*IntSet
+	for _, set := range sets {
+		if set.chunks != nil {
+			chunks = append(chunks, set.chunks...)
+		} else {
+			chunks = append(chunks, set.splitChunks()...)
+		}
+	}
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].minValue < chunks[j].minValue })
+	var result []*IntSet
+	for i := 0; i < len(chunks); {
+		j := i + 1
+		for j < len(chunks) && chunkKey(chunks[j].minValue) == chunkKey(chunks[i].minValue) {
+			j++
+		}
+		if j-i >= k {
+			if chunk := AtLeast(k, chunks[i:j]...); !chunk.IsEmpty() {
+				result = append(result, chunk)
+			}
+		}
+		i = j
+	}
+	return NewIntSet().setChunks(result)
+}
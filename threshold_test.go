@@ -0,0 +1,51 @@
+package bitset
+
+import (
+	"testing"
+)
+
+func TestAtLeast(test *testing.T) {
+	for _, sets := range [][]*IntSet{
+		{NewIntSetFromInterval(0, 100), NewIntSetFromInterval(50, 150), NewIntSetFromInterval(90, 200), newRunsSet()},
+		newPostings(1, 20),
+		append(newPostings(2, 7), newChunkedSet(), newChunkedSet().RemoveRange(0, 1<<33)),
+		{newStepSet(), NewIntSetFromInterval(1<<20+1000, 1<<20+1005), NewIntSetFromInterval(1000, 1<<20+1002)},
+	} {
+		counts := map[uint]int{}
+		for _, set := range sets {
+			for v := range set.All() {
+				counts[v]++
+			}
+		}
+		for k := 0; k <= len(sets)+1; k++ {
+			result := AtLeast(k, sets...)
+			var size uint
+			for v, count := range counts {
+				if expected := count >= k; result.Contains(v) != expected {
+					test.Error("Bad member of at least", k, "of", len(sets), ":", v, "should be", expected)
+					break
+				}
+				if count >= k {
+					size++
+				}
+			}
+			if result.Size() != size {
+				test.Error("Bad count of at least", k, "of", len(sets), ":", result.Size(), "should be", size)
+			}
+		}
+	}
+}
+
+func TestAtLeastBitSetStart(test *testing.T) {
+	// removing the first members leaves the words starting below the minimum
+	set := NewIntSetFromUInts([]uint{0, 5, 200, 300, 310})
+	set.Remove(0)
+	set.Remove(5)
+	if set.vsStart >= set.minValue&^0x3F {
+		test.Error("Bad test set: words start at", set.vsStart, "with minimum", set.minValue)
+	}
+	result := AtLeast(2, set, NewIntSetFromUInts([]uint{250, 300, 310}), NewIntSetFromUInts([]uint{300, 400}))
+	if !sameMembers(result, NewIntSetFromUInts([]uint{300, 310})) {
+		test.Error("Bad at least 2 with words below the minimum:", result.AsUints(), "should be", []uint{300, 310})
+	}
+}